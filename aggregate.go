@@ -0,0 +1,157 @@
+package blynk
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Aggregation int
+
+const (
+	AGGREGATE_MEAN Aggregation = iota
+	AGGREGATE_MIN
+	AGGREGATE_MAX
+	AGGREGATE_LAST
+	AGGREGATE_COUNT
+)
+
+type AggregateResult struct {
+	Mean  float64
+	Min   float64
+	Max   float64
+	Last  float64
+	Count int
+}
+
+func (r AggregateResult) Value(a Aggregation) float64 {
+	switch a {
+	case AGGREGATE_MEAN:
+		return r.Mean
+	case AGGREGATE_MIN:
+		return r.Min
+	case AGGREGATE_MAX:
+		return r.Max
+	case AGGREGATE_LAST:
+		return r.Last
+	case AGGREGATE_COUNT:
+		return float64(r.Count)
+	default:
+		return math.NaN()
+	}
+}
+
+// Aggregator collects samples during the window and pushes the aggregated values to the pins at the end of the window.
+// Empty windows are not sent.
+type Aggregator struct {
+	blynk   *Blynk
+	window  time.Duration
+	pins    map[Aggregation]int
+	lock    sync.Mutex
+	start   time.Time
+	result  AggregateResult
+	sum     float64
+	timerID int
+}
+
+func (g *Blynk) NewAggregator(window time.Duration, pins map[Aggregation]int) *Aggregator {
+	a := &Aggregator{
+		blynk:  g,
+		window: window,
+		pins:   pins,
+		start:  g.clock.Now(),
+	}
+	a.timerID = g.timer.SetInterval(window, a.tick)
+	return a
+}
+
+func (a *Aggregator) Close() {
+	a.blynk.timer.Delete(a.timerID)
+}
+
+func (a *Aggregator) Record(v float64) {
+	a.lock.Lock()
+	result, expired := a.rollExpired()
+	r := &a.result
+	if r.Count == 0 || v < r.Min {
+		r.Min = v
+	}
+	if r.Count == 0 || v > r.Max {
+		r.Max = v
+	}
+	r.Last = v
+	r.Count++
+	a.sum += v
+	a.lock.Unlock()
+
+	if expired {
+		if err := a.send(result); err != nil {
+			a.blynk.log.Printf("[ERROR] aggregator: send failed, %s", err.Error())
+		}
+	}
+}
+
+// Flush sends the samples collected so far, the current window still ends on schedule
+func (a *Aggregator) Flush() error {
+	a.lock.Lock()
+	result := a.roll()
+	a.lock.Unlock()
+
+	if result.Count == 0 {
+		return nil
+	}
+	return a.send(result)
+}
+
+func (a *Aggregator) tick() {
+	a.lock.Lock()
+	result, expired := a.rollExpired()
+	a.lock.Unlock()
+
+	if expired {
+		if err := a.send(result); err != nil {
+			a.blynk.log.Printf("[ERROR] aggregator: send failed, %s", err.Error())
+		}
+	}
+}
+
+// rollExpired closes the window if it is over, it reports false for windows without samples.
+// Windows follow the timer schedule, so a late tick does not stretch the next window
+func (a *Aggregator) rollExpired() (AggregateResult, bool) {
+	elapsed := a.blynk.clock.Now().Sub(a.start)
+	if elapsed < a.window {
+		return AggregateResult{}, false
+	}
+	a.start = a.start.Add(elapsed - elapsed%a.window)
+	result := a.roll()
+	return result, result.Count > 0
+}
+
+// roll takes the result of collected samples, the window bounds are kept
+func (a *Aggregator) roll() AggregateResult {
+	result := a.result
+	if result.Count > 0 {
+		result.Mean = a.sum / float64(result.Count)
+	}
+	a.result = AggregateResult{}
+	a.sum = 0
+	return result
+}
+
+func (a *Aggregator) send(result AggregateResult) error {
+	kinds := make([]int, 0, len(a.pins))
+	for kind := range a.pins {
+		kinds = append(kinds, int(kind))
+	}
+	sort.Ints(kinds)
+
+	for _, kind := range kinds {
+		v := result.Value(Aggregation(kind))
+		if err := a.blynk.VirtualWrite(a.pins[Aggregation(kind)], strconv.FormatFloat(v, 'f', -1, 64)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package blynk
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestAggregator() (*Aggregator, *recordConn, *fakeClock) {
+	g, conn := newRecordBlynk()
+	clock := newFakeClock()
+	g.SetClock(clock)
+	// the timer is paused until the device is connected
+	g.Timer().Resume()
+	a := g.NewAggregator(10*time.Second, map[Aggregation]int{
+		AGGREGATE_MEAN:  1,
+		AGGREGATE_MAX:   2,
+		AGGREGATE_COUNT: 3,
+	})
+	return a, conn, clock
+}
+
+func assertWrites(t *testing.T, got []byte, want ...string) {
+	t.Helper()
+	var exp []byte
+	for i := 0; i < len(want); i += 2 {
+		exp = append(exp, "vw\x00"+want[i]+"\x00"+want[i+1]...)
+	}
+	// compare bodies only, message ids are not interesting here
+	var bodies []byte
+	for len(got) >= BLYNK_HEAD_SIZE {
+		n := int(got[3])<<8 | int(got[4])
+		bodies = append(bodies, got[BLYNK_HEAD_SIZE:BLYNK_HEAD_SIZE+n]...)
+		got = got[BLYNK_HEAD_SIZE+n:]
+	}
+	if !bytes.Equal(bodies, exp) {
+		t.Fatalf("writes %q, want %q", bodies, exp)
+	}
+}
+
+func TestAggregatorWindow(t *testing.T) {
+	a, conn, clock := newTestAggregator()
+	timer := a.blynk.Timer()
+
+	a.Record(1)
+	a.Record(4)
+	a.Record(1)
+	clock.Advance(5 * time.Second)
+	timer.Run()
+	assertWrites(t, conn.Take())
+
+	clock.Advance(5 * time.Second)
+	timer.Run()
+	assertWrites(t, conn.Take(), "1", "2", "2", "4", "3", "3")
+
+	// an empty window is not sent
+	clock.Advance(10 * time.Second)
+	timer.Run()
+	assertWrites(t, conn.Take())
+}
+
+func TestAggregatorRecordRollsExpiredWindow(t *testing.T) {
+	a, conn, clock := newTestAggregator()
+
+	a.Record(2)
+	clock.Advance(10 * time.Second)
+	// the sample goes to the new window, the old one is sent first
+	a.Record(8)
+	assertWrites(t, conn.Take(), "1", "2", "2", "2", "3", "1")
+
+	a.Close()
+	clock.Advance(10 * time.Second)
+	a.blynk.Timer().Run()
+	assertWrites(t, conn.Take())
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	assertWrites(t, conn.Take(), "1", "8", "2", "8", "3", "1")
+}
+
+func TestAggregatorFlush(t *testing.T) {
+	a, conn, clock := newTestAggregator()
+
+	// nothing is sent for an empty window
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	assertWrites(t, conn.Take())
+
+	clock.Advance(5 * time.Second)
+	a.Record(3)
+	a.Record(5)
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	assertWrites(t, conn.Take(), "1", "4", "2", "5", "3", "2")
+
+	// flush does not move the window, samples after it are sent at the end of the window
+	a.Record(1)
+	clock.Advance(5 * time.Second)
+	a.blynk.Timer().Run()
+	assertWrites(t, conn.Take(), "1", "1", "2", "1", "3", "1")
+}
+
+func TestAggregatorLateTick(t *testing.T) {
+	a, conn, clock := newTestAggregator()
+	timer := a.blynk.Timer()
+
+	// the timer goroutine wakes up a bit late, by a different time on every tick
+	late := time.Duration(0)
+	for i, d := range []time.Duration{3 * time.Millisecond, time.Millisecond, 4 * time.Millisecond} {
+		a.Record(float64(i + 1))
+		clock.Advance(10*time.Second + d - late)
+		late = d
+		timer.Run()
+		v := strconv.Itoa(i + 1)
+		assertWrites(t, conn.Take(), "1", v, "2", v, "3", "1")
+	}
+}
+
+func TestAggregatorRecordKeepsSchedule(t *testing.T) {
+	a, conn, clock := newTestAggregator()
+	timer := a.blynk.Timer()
+
+	a.Record(2)
+	clock.Advance(12 * time.Second)
+	// the first window is rolled by the sample, the second one still ends at 20s
+	a.Record(4)
+	assertWrites(t, conn.Take(), "1", "2", "2", "2", "3", "1")
+	clock.Advance(8 * time.Second)
+	timer.Run()
+	assertWrites(t, conn.Take(), "1", "4", "2", "4", "3", "1")
+}
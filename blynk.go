@@ -5,40 +5,95 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io"
+	"math"
 	"net"
+	"net/url"
 	"runtime"
-	"strings"
 	"sync"
 	"time"
 
+	"github.com/OloloevReal/go-blynk/capture"
 	certs "github.com/OloloevReal/go-blynk/certs"
-	slog "github.com/OloloevReal/go-simple-log"
+	"github.com/OloloevReal/go-blynk/websocket"
 )
 
 const Version = "0.0.5"
 
+const BLYNK_WEBSOCKET_PATH = "/websocket"
+
 type Blynk struct {
 	APIkey          string
 	server          string
 	port            int
 	OnReadFunc      func(*BlynkRespose)
+	OnResponseFunc  func(*BlynkRespose)
 	conn            net.Conn
 	msgID           uint16
 	processingUsing bool
+	processingDone  chan struct{}
 	disableLogo     bool
 	heartbeat       time.Duration
 	timeout         time.Duration
 	timeoutMAX      time.Duration
 	lock            sync.Mutex
+	handlerLock     sync.Mutex
 	ssl             bool
 	cancel          chan bool
 	readers         map[uint]func(uint, io.Writer)
 	writers         map[uint]func(uint, io.Reader)
+	valueWriters    map[uint]func(uint, []string)
 	recvMsg         chan []byte
+	rtcWaiters      map[uint16]chan time.Time
+	statusWaiters   map[uint16]chan uint16
+	rtcSync         time.Duration
+	rtcOffset       time.Duration
+	rtcLocation     *time.Location
+	clock           Clock
+	timer           *Timer
+	reports         map[int]*pinReport
+	metrics         Metrics
+	pings           map[uint16]time.Time
+	connects        int
+	maxMissedPings  int
+	missedPings     int
+	lastRTT         time.Duration
+	avgRTT          time.Duration
+	tlsConfig       *tls.Config
+	dialer          *net.Dialer
+	managed         bool
+	heartbeatBusy   bool
+	connected       bool
+	recorder        *capture.Recorder
+	pinWatchers     map[int]func(int, []string)
+	lastWatcher     int
+	pinMeta         map[uint]PinMeta
+	webSocket       bool
+	webSocketPath   string
+	log             *deviceLog
+}
+
+const (
+	PIN_WIDGET_SENSOR        = "sensor"
+	PIN_WIDGET_BINARY_SENSOR = "binary_sensor"
+	PIN_WIDGET_SWITCH        = "switch"
+	PIN_WIDGET_NUMBER        = "number"
+)
+
+// PinMeta describes the virtual pin for integrations like Home Assistant discovery.
+// Min, Max and Step are used when Max is greater than Min.
+type PinMeta struct {
+	Name        string
+	Unit        string
+	Widget      string
+	DeviceClass string
+	Icon        string
+	Min         float64
+	Max         float64
+	Step        float64
 }
 
 func NewBlynk(APIkey string) *Blynk {
-	return &Blynk{APIkey: APIkey,
+	b := &Blynk{APIkey: APIkey,
 		server:          "blynk-cloud.com",
 		port:            443,
 		conn:            nil,
@@ -53,8 +108,24 @@ func NewBlynk(APIkey string) *Blynk {
 		cancel:          make(chan bool, 1),
 		writers:         make(map[uint]func(uint, io.Reader)),
 		readers:         make(map[uint]func(uint, io.Writer)),
+		valueWriters:    make(map[uint]func(uint, []string)),
 		recvMsg:         make(chan []byte, 10),
-	}
+		rtcWaiters:      make(map[uint16]chan time.Time),
+		statusWaiters:   make(map[uint16]chan uint16),
+		rtcLocation:     time.Local,
+		clock:           realClock{},
+		reports:         make(map[int]*pinReport),
+		metrics:         nopMetrics{},
+		pings:           make(map[uint16]time.Time),
+		maxMissedPings:  3,
+		pinWatchers:     make(map[int]func(int, []string)),
+		pinMeta:         make(map[uint]PinMeta),
+		webSocketPath:   BLYNK_WEBSOCKET_PATH,
+		log:             newDeviceLog(),
+	}
+	b.timer = NewTimer(b.clock)
+	b.timer.Pause()
+	return b
 }
 
 func (g *Blynk) SetUseSSL(ssl bool) {
@@ -72,8 +143,35 @@ func (g *Blynk) SetServer(Server string, Port int, SSL bool) {
 	g.ssl = SSL
 }
 
+// Server returns the server address and SSL flag of the connection
+func (g *Blynk) Server() (string, int, bool) {
+	return g.server, g.port, g.ssl
+}
+
+// SetDebug enables debug lines for this device only
 func (g *Blynk) SetDebug() {
-	slog.SetOptions(slog.SetDebug)
+	g.log.setDebug(true)
+}
+
+// SetLogger sends log lines of the device to l, nil restores the standard logger
+func (g *Blynk) SetLogger(l Logger) {
+	g.log.setOutput(l)
+}
+
+// SetResponseTimeout sets how long login, server time requests and notify, tweet and email wait for the answer
+func (g *Blynk) SetResponseTimeout(d time.Duration) {
+	g.timeoutMAX = d
+}
+
+// SetClock replaces the clock used by the timer, it should be called before the timer is used
+func (g *Blynk) SetClock(clock Clock) {
+	g.clock = clock
+	g.timer.setClock(clock)
+}
+
+// Timer returns the timer of the connection, callbacks run while Processing is active and are paused while disconnected
+func (g *Blynk) Timer() *Timer {
+	return g.timer
 }
 
 func (g *Blynk) DisableLogo(state bool) {
@@ -94,9 +192,10 @@ func (g *Blynk) printLogo() {
 
 
 `
-	slog.Printf(logo, Version, runtime.GOOS)
+	g.log.Printf(logo, Version, runtime.GOOS)
 }
 
+// AddReaderHandler sets the handler of reads from the app, handlers of the device are never called concurrently
 func (g *Blynk) AddReaderHandler(pin uint, fn func(pin uint, writer io.Writer)) {
 	g.lock.Lock()
 	defer g.lock.Unlock()
@@ -109,6 +208,7 @@ func (g *Blynk) DeleteReaderHandler(pin uint) {
 	delete(g.readers, pin)
 }
 
+// AddWriterHandler sets the handler of writes from the app, handlers of the device are never called concurrently
 func (g *Blynk) AddWriterHandler(pin uint, fn func(pin uint, reader io.Reader)) {
 	g.lock.Lock()
 	defer g.lock.Unlock()
@@ -121,19 +221,71 @@ func (g *Blynk) DeleteWriterHandler(pin uint) {
 	delete(g.writers, pin)
 }
 
+func (g *Blynk) setValuesHandler(pin uint, fn func(pin uint, values []string)) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.valueWriters[pin] = fn
+}
+
+func (g *Blynk) deleteValuesHandler(pin uint) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	delete(g.valueWriters, pin)
+}
+
+func (g *Blynk) SetPinMeta(pin uint, meta PinMeta) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.pinMeta[pin] = meta
+}
+
+func (g *Blynk) DeletePinMeta(pin uint) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	delete(g.pinMeta, pin)
+}
+
+func (g *Blynk) PinMeta(pin uint) (PinMeta, bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	meta, ok := g.pinMeta[pin]
+	return meta, ok
+}
+
+// PinsMeta returns a copy of the metadata of all described pins
+func (g *Blynk) PinsMeta() map[uint]PinMeta {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	pins := make(map[uint]PinMeta, len(g.pinMeta))
+	for pin, meta := range g.pinMeta {
+		pins[pin] = meta
+	}
+	return pins
+}
+
 func (g *Blynk) Connect() error {
 
-	g.printLogo()
+	if g.connects == 0 {
+		g.printLogo()
+	}
 
 	addr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", g.server, g.port))
 	if err != nil {
 		return err
 	}
 
-	if g.ssl {
-		g.conn, err = g.dialTLS(addr)
+	dialer := g.dialer
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+
+	var conn net.Conn
+	if g.webSocket {
+		conn, err = g.dialWebSocket(dialer)
+	} else if g.ssl {
+		conn, err = g.dialTLS(dialer, addr)
 	} else {
-		g.conn, err = net.DialTCP("tcp", nil, addr)
+		conn, err = dialer.Dial("tcp", addr.String())
 	}
 
 	if err != nil {
@@ -141,16 +293,99 @@ func (g *Blynk) Connect() error {
 	}
 	//defer conn.Close()
 
+	g.lock.Lock()
+	g.conn = conn
+	g.pings = make(map[uint16]time.Time)
+	g.missedPings = 0
+	g.lock.Unlock()
+
 	if err = g.auth(); err != nil {
+		g.metrics.AuthFailure()
 		return err
 	}
-	slog.Printf("Connect: Auth success (SSL: %v)", g.ssl)
+	g.log.Printf("Connect: Auth success (SSL: %v)", g.ssl)
+	if g.connects > 0 {
+		g.metrics.Reconnect()
+	}
+	g.connects++
+	g.setConnected(true)
 
 	g.sendInternal()
+	g.timer.Resume()
 	return nil
 }
 
-func (g *Blynk) dialTLS(addr *net.TCPAddr) (*tls.Conn, error) {
+// SetTLSConfig replaces the default TLS config with the built-in Blynk certificate, ServerName is set on connect
+func (g *Blynk) SetTLSConfig(conf *tls.Config) {
+	g.tlsConfig = conf
+}
+
+func (g *Blynk) SetDialer(dialer *net.Dialer) {
+	g.dialer = dialer
+}
+
+// SetRecorder writes all bytes sent and received on the connection to the capture, login tokens are redacted.
+// nil disables recording
+func (g *Blynk) SetRecorder(rec *capture.Recorder) {
+	g.recorder = rec
+}
+
+func (g *Blynk) IsConnected() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.connected
+}
+
+func (g *Blynk) setConnected(connected bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.connected = connected
+}
+
+func (g *Blynk) dialTLS(dialer *net.Dialer, addr *net.TCPAddr) (*tls.Conn, error) {
+	conf, err := g.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return tls.DialWithDialer(dialer, "tcp", addr.String(), conf)
+}
+
+// SetUseWebSocket carries the frames over WebSocket on the HTTP port of the server, wss is used with SSL
+func (g *Blynk) SetUseWebSocket(enabled bool) {
+	g.webSocket = enabled
+}
+
+// SetWebSocketPath changes the WebSocket endpoint, default is /websocket
+func (g *Blynk) SetWebSocketPath(path string) {
+	g.webSocketPath = path
+}
+
+func (g *Blynk) dialWebSocket(dialer *net.Dialer) (net.Conn, error) {
+	u := url.URL{Scheme: "ws", Host: fmt.Sprintf("%s:%d", g.server, g.port), Path: g.webSocketPath}
+	var conf *tls.Config
+	if g.ssl {
+		u.Scheme = "wss"
+		var err error
+		if conf, err = g.TLSConfig(); err != nil {
+			return nil, err
+		}
+	}
+	return websocket.Dial(dialer, u.String(), conf)
+}
+
+// TLSConfig returns the config used for connections: the one set by SetTLSConfig or the default with Blynk certificate
+func (g *Blynk) TLSConfig() (*tls.Config, error) {
+	if g.tlsConfig != nil {
+		conf := g.tlsConfig.Clone()
+		if conf.ServerName == "" {
+			conf.ServerName = g.server
+		}
+		return conf, nil
+	}
+	return g.defaultTLSConfig()
+}
+
+func (g *Blynk) defaultTLSConfig() (*tls.Config, error) {
 	roots := x509.NewCertPool()
 	rootPEM, err := g.loadCA()
 	if err != nil {
@@ -162,7 +397,7 @@ func (g *Blynk) dialTLS(addr *net.TCPAddr) (*tls.Conn, error) {
 	}
 
 	//w := os.Stdout
-	conf := tls.Config{
+	conf := &tls.Config{
 		InsecureSkipVerify:     false,
 		MinVersion:             tls.VersionTLS12,
 		RootCAs:                roots,
@@ -170,8 +405,7 @@ func (g *Blynk) dialTLS(addr *net.TCPAddr) (*tls.Conn, error) {
 		SessionTicketsDisabled: true,
 		//KeyLogWriter:           w,
 	}
-	conn, err := tls.Dial("tcp", addr.String(), &conf)
-	return conn, err
+	return conf, nil
 }
 
 func (g *Blynk) loadCA() ([]byte, error) {
@@ -179,11 +413,48 @@ func (g *Blynk) loadCA() ([]byte, error) {
 }
 
 func (g *Blynk) Processing() {
+	done := make(chan struct{})
+	g.lock.Lock()
+	g.processingDone = done
+	g.lock.Unlock()
+	defer close(done)
 	g.processingUsing = true
 	defer func() { g.processingUsing = false }()
-	go g.keepAlive()
-	go g.processor()
-	g.receiver()
+	if g.managed {
+		//manager runs the shared timer, frames are processed by receiver
+		heartbeatID := g.timer.SetInterval(g.heartbeat, g.heartbeatAsync)
+		defer g.timer.Delete(heartbeatID)
+	} else {
+		go g.keepAlive()
+		go g.processor()
+		go g.timer.loop(g.cancel)
+	}
+	if g.rtcSync > 0 && g.managed {
+		rtcID := g.timer.SetInterval(g.rtcSync, func() { go g.sendRTCSync() })
+		defer g.timer.Delete(rtcID)
+	} else if g.rtcSync > 0 {
+		go g.rtcSyncer()
+	}
+	for {
+		err := g.receiver()
+		if g.stopped() {
+			return
+		}
+		g.log.Printf("[ERROR] Processing: connection lost, %v", err)
+		g.Disconnect()
+		if !g.reconnect() {
+			return
+		}
+	}
+}
+
+func (g *Blynk) stopped() bool {
+	select {
+	case <-g.cancel:
+		return true
+	default:
+		return false
+	}
 }
 
 func (g *Blynk) getMessageID() uint16 {
@@ -197,7 +468,7 @@ func (g *Blynk) getMessageID() uint16 {
 }
 
 func (g *Blynk) auth() error {
-	_, err := g.sendString(BLYNK_CMD_HW_LOGIN, g.APIkey)
+	id, err := g.sendString(BLYNK_CMD_HW_LOGIN, g.APIkey)
 	if err != nil {
 		return err
 	}
@@ -207,14 +478,23 @@ func (g *Blynk) auth() error {
 		return err
 	}
 
-	if response != nil && (response.MessageId != g.msgID || response.Command != BLYNK_CMD_RESPONSE || response.Length != BLYNK_SUCCESS) {
-		return fmt.Errorf("auth: failed, message id-%d, code-%d", response.MessageId, response.Length)
+	if response.MessageId != id || response.Command != BLYNK_CMD_RESPONSE {
+		return fmt.Errorf("auth: failed, unexpected message id-%d, command-%d", response.MessageId, response.Command)
+	}
+	if response.Length != BLYNK_SUCCESS {
+		return newStatusError(BLYNK_CMD_HW_LOGIN, response)
 	}
 	return nil
 }
 
 func (g *Blynk) sendInternal() error {
-	if _, err := g.sendString(BLYNK_CMD_INTERNAL, g.formatInternal()); err != nil {
+	msg := BlynkMessage{}
+	msg.Head.Command = BLYNK_CMD_INTERNAL
+	msg.Head.MessageId = g.getMessageID()
+	msg.Body.AddString(g.formatInternal()...)
+	msg.Head.Length = msg.Body.Len()
+
+	if _, err := g.sendMessage(msg); err != nil {
 		return err
 	}
 
@@ -224,48 +504,45 @@ func (g *Blynk) sendInternal() error {
 	}
 
 	if resp.Length != BLYNK_SUCCESS {
-		return fmt.Errorf("sendInternal: received unsuccessful code %d", resp.Length)
+		return newStatusError(BLYNK_CMD_INTERNAL, resp)
 	}
 
 	return nil
 }
 
-func (g *Blynk) formatInternal() string {
+func (g *Blynk) formatInternal() []string {
 	rcv_buffer := "1024"
-	params := []string{"ver", Version, "buff-in", rcv_buffer, "h-beat", fmt.Sprintf("%.0f", g.heartbeat.Seconds()), "dev", "go"}
-	return strings.Join(params, string(0x00))
+	//server counts heartbeat in whole seconds, round up to not be disconnected before the local ping
+	heartbeat := math.Max(1, math.Ceil(g.heartbeat.Seconds()))
+	return []string{"ver", Version, "buff-in", rcv_buffer, "h-beat", fmt.Sprintf("%.0f", heartbeat), "dev", "go"}
 }
 
-func (g *Blynk) keepAlive() {
-	slog.Printf("Keep-Alive: started")
-	defer slog.Printf("Keep-Alive: finished")
-	t := time.NewTicker(g.heartbeat)
-	for {
-		select {
-		case <-t.C:
-			slog.Printf("[DEBUG] Keep-Alive: send")
-			g.sendCommand(BLYNK_CMD_PING)
-		case <-g.cancel:
-			slog.Printf("[DEBUG] Keep-Alive: Stop received")
-			t.Stop()
-			return
-		}
+func (g *Blynk) VirtualWrite(pin int, values ...string) error {
+	//report policy is applied to single value writes only
+	single := len(values) == 1
+	if single && !g.shouldReport(pin, values[0]) {
+		return nil
+	}
+
+	if _, err := g.sendMessage(g.virtualWriteMessage(pin, values...)); err != nil {
+		return err
+	}
+	if single {
+		g.reported(pin, values[0])
 	}
+	g.notifyPin(pin, values)
+	return nil
 }
 
-func (g *Blynk) VirtualWrite(pin int, value string) error {
+func (g *Blynk) virtualWriteMessage(pin int, values ...string) BlynkMessage {
 	msg := BlynkMessage{}
 	msg.Head.Command = BLYNK_CMD_HARDWARE
 	msg.Head.MessageId = g.getMessageID()
 	msg.Body.AddString("vw")
 	msg.Body.AddInt(pin)
-	msg.Body.AddString(value)
+	msg.Body.AddString(values...)
 	msg.Head.Length = msg.Body.Len()
-
-	if _, err := g.sendMessage(msg); err != nil {
-		return err
-	}
-	return nil
+	return msg
 }
 
 func (g *Blynk) VirtualRead(pins ...int) error {
@@ -313,47 +590,13 @@ func (g *Blynk) DigitalRead(pin int) error {
 	return nil
 }
 
+// Notify sends push notification and waits for the status of the server, e.g. ErrQuotaLimit
 func (g *Blynk) Notify(msg string) error {
-	_, err := g.sendString(BLYNK_CMD_NOTIFY, msg)
-	if err != nil {
-		return fmt.Errorf("send notify failed, %s", err.Error())
-	}
-
-	//if receiver is using dont use standalone receive func
-	if g.processingUsing {
-		return err
-	}
-
-	bh, err := g.receiveMessage(time.Duration(time.Second * 5))
-	if err != nil {
-		return err
-	}
-	if bh.Length != BLYNK_SUCCESS {
-		return fmt.Errorf("notify failed, cause: %s (%d)", GetBlynkStatus(bh.Length), bh.Length)
-	}
-
-	return nil
+	return g.sendWithStatus(g.stringMessage(BLYNK_CMD_NOTIFY, msg))
 }
 
 func (g *Blynk) Tweet(msg string) error {
-	_, err := g.sendString(BLYNK_CMD_TWEET, msg)
-	if err != nil {
-		return fmt.Errorf("send tweet failed, %s", err.Error())
-	}
-
-	if g.processingUsing {
-		return err
-	}
-
-	bh, err := g.receiveMessage(time.Duration(time.Second * 5))
-	if err != nil {
-		return err
-	}
-	if bh.Length != BLYNK_SUCCESS {
-		return fmt.Errorf("tweet failed, cause: %s (%d)", GetBlynkStatus(bh.Length), bh.Length)
-	}
-
-	return nil
+	return g.sendWithStatus(g.stringMessage(BLYNK_CMD_TWEET, msg))
 }
 
 func (g *Blynk) EMail(to string, subject string, msg string) error {
@@ -366,40 +609,44 @@ func (g *Blynk) EMail(to string, subject string, msg string) error {
 	bmsg.Body.AddString(msg)
 	bmsg.Head.Length = bmsg.Body.Len()
 
-	_, err := g.sendMessage(bmsg)
-
-	//if receiver is using dont use standalone receive func
-	if g.processingUsing {
-		return err
-	}
-
-	bh, err := g.receiveMessage(time.Duration(time.Second * 5))
-	if err != nil {
-		return err
-	}
-	if bh.Length != BLYNK_SUCCESS {
-		return fmt.Errorf("email failed, cause: %s (%d)", GetBlynkStatus(bh.Length), bh.Length)
-	}
-
-	return nil
+	return g.sendWithStatus(bmsg)
 }
 
 func (g *Blynk) Stop() error {
 	if g == nil {
 		return fmt.Errorf("Blynk: source object blynk is nil")
 	}
-	slog.Printf("[DEBUG] Sending to cancle channel")
+	g.log.Printf("[DEBUG] Sending to cancle channel")
 	g.conn.SetReadDeadline(time.Now().Add(time.Millisecond * 500))
 	close(g.cancel)
-	close(g.recvMsg)
+	g.timer.DeleteAll()
 	time.Sleep(time.Second * 1)
 	return g.Disconnect()
 }
 
+// Shutdown stops the device like Stop, but closes the connection at once and waits until Processing returns
+func (g *Blynk) Shutdown() error {
+	if g == nil {
+		return fmt.Errorf("Blynk: source object blynk is nil")
+	}
+	close(g.cancel)
+	g.timer.DeleteAll()
+	err := g.Disconnect()
+	g.lock.Lock()
+	done := g.processingDone
+	g.lock.Unlock()
+	if done != nil {
+		<-done
+	}
+	return err
+}
+
 func (g *Blynk) Disconnect() error {
 	if g == nil || g.conn == nil {
 		return fmt.Errorf("disconnect: *Blynk or *net.TCPConn is nil")
 	}
+	g.timer.Pause()
+	g.setConnected(false)
 	err := g.conn.Close()
 	return err
 }
@@ -0,0 +1,129 @@
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/OloloevReal/go-blynk/protocol"
+)
+
+const (
+	DIR_SEND = "send"
+	DIR_RECV = "recv"
+)
+
+// Record is one line of the capture, Data is what was written to or read from the connection by the client
+type Record struct {
+	Offset    time.Duration `json:"offset"`
+	Direction string        `json:"dir"`
+	Data      string        `json:"data"`
+}
+
+func (r *Record) Bytes() ([]byte, error) {
+	return hex.DecodeString(r.Data)
+}
+
+// Recorder writes client traffic as JSON lines, tokens and passwords of login frames are redacted
+type Recorder struct {
+	lock  sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+	err   error
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w), start: time.Now()}
+}
+
+func (r *Recorder) Sent(buf []byte) {
+	r.write(DIR_SEND, redactLogin(buf))
+}
+
+func (r *Recorder) Received(buf []byte) {
+	r.write(DIR_RECV, buf)
+}
+
+// Err returns the first write error, the recorder stops writing after it
+func (r *Recorder) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+func (r *Recorder) write(dir string, buf []byte) {
+	if r == nil || len(buf) == 0 {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(Record{
+		Offset:    time.Since(r.start),
+		Direction: dir,
+		Data:      hex.EncodeToString(buf),
+	})
+}
+
+func ReadRecords(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// redactLogin masks login fields except the first 4 bytes of long ones, the length is kept so the frame stays valid
+func redactLogin(buf []byte) []byte {
+	var out []byte
+	rest := buf
+	for len(rest) > 0 {
+		f, n, err := protocol.DecodeFrame(rest)
+		if err != nil {
+			break
+		}
+		if isLogin(f.Command) {
+			if out == nil {
+				out = append([]byte(nil), buf...)
+			}
+			start := len(buf) - len(rest) + protocol.HEAD_SIZE
+			redactFields(out[start : start+len(f.Body)])
+		}
+		rest = rest[n:]
+	}
+	if out == nil {
+		return buf
+	}
+	return out
+}
+
+func isLogin(cmd protocol.Command) bool {
+	return cmd == protocol.CMD_LOGIN || cmd == protocol.CMD_HW_LOGIN
+}
+
+func redactFields(body []byte) {
+	for _, field := range bytes.Split(body, []byte{0x00}) {
+		keep := 4
+		if len(field) <= 8 {
+			keep = 0
+		}
+		for i := keep; i < len(field); i++ {
+			field[i] = '*'
+		}
+	}
+}
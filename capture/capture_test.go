@@ -0,0 +1,123 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OloloevReal/go-blynk/protocol"
+)
+
+func frame(t *testing.T, cmd protocol.Command, id uint16, values ...string) []byte {
+	t.Helper()
+	f, err := protocol.NewFrame(cmd, id, values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := protocol.AppendFrame(nil, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestRecorderRedactsLogin(t *testing.T) {
+	var out bytes.Buffer
+	rec := NewRecorder(&out)
+	login := frame(t, protocol.CMD_HW_LOGIN, 1, "0123456789abcdef")
+	write := frame(t, protocol.CMD_HARDWARE, 2, "vw", "1", "42")
+	rec.Sent(append(append([]byte(nil), login...), write...))
+
+	if strings.Contains(out.String(), "3435363738") {
+		t.Fatalf("token is written to the capture: %s", out.String())
+	}
+	records, err := ReadRecords(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := records[0].Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(frame(t, protocol.CMD_HW_LOGIN, 1, "0123************"), write...)
+	if !bytes.Equal(buf, want) {
+		t.Fatalf("recorded % x, want % x", buf, want)
+	}
+	if !bytes.Contains(login, []byte("0123456789abcdef")) {
+		t.Fatal("recorder changed the sent buffer")
+	}
+}
+
+// script is a capture of login and one write
+func script(t *testing.T) []Record {
+	return []Record{
+		{Direction: DIR_SEND, Data: hexFrame(t, protocol.CMD_HW_LOGIN, 1, "0123************")},
+		{Direction: DIR_RECV, Data: "00000100c8"},
+		{Direction: DIR_SEND, Data: hexFrame(t, protocol.CMD_HARDWARE, 2, "vw", "1", "10")},
+	}
+}
+
+func hexFrame(t *testing.T, cmd protocol.Command, id uint16, values ...string) string {
+	return hex.EncodeToString(frame(t, cmd, id, values...))
+}
+
+// replay runs the script against frames sent by a fake client and returns the report
+func replay(t *testing.T, frames ...[]byte) *Report {
+	t.Helper()
+	r, err := NewReplayer(script(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.KeepTiming = false
+	r.Timeout = time.Second
+	r.TailWait = 100 * time.Millisecond
+
+	server, client := net.Pipe()
+	go func() {
+		for _, f := range frames {
+			client.Write(f)
+		}
+	}()
+	// answers are read until the replayer closes the connection
+	go io.Copy(io.Discard, client)
+	return r.Serve(server)
+}
+
+func TestReplayerMatchesRedactedLogin(t *testing.T) {
+	report := replay(t,
+		frame(t, protocol.CMD_HW_LOGIN, 1, "0123456789abcdef"),
+		frame(t, protocol.CMD_HARDWARE, 2, "vw", "1", "10"),
+	)
+	if !report.OK() {
+		t.Fatalf("report %+v", report)
+	}
+}
+
+func TestReplayerReportsExtraFrames(t *testing.T) {
+	report := replay(t,
+		frame(t, protocol.CMD_HW_LOGIN, 1, "0123456789abcdef"),
+		frame(t, protocol.CMD_HARDWARE, 2, "vw", "1", "10"),
+		frame(t, protocol.CMD_PING, 3),
+		frame(t, protocol.CMD_HARDWARE, 4, "vw", "2", "20"),
+	)
+	if report.OK() || len(report.Divergences) != 1 {
+		t.Fatalf("report %+v, want one divergence", report)
+	}
+	if d := report.Divergences[0]; d.Expected != "end of capture" || !strings.Contains(d.Actual, "vw | 2 | 20") {
+		t.Fatalf("divergence %+v", d)
+	}
+}
+
+func TestReplayerReportsChangedFrame(t *testing.T) {
+	report := replay(t,
+		frame(t, protocol.CMD_HW_LOGIN, 1, "0123456789abcdef"),
+		frame(t, protocol.CMD_HARDWARE, 2, "vw", "1", "11"),
+	)
+	if report.OK() || report.Matched != 1 || len(report.Divergences) != 1 {
+		t.Fatalf("report %+v, want one match and one divergence", report)
+	}
+}
@@ -0,0 +1,211 @@
+package capture
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/OloloevReal/go-blynk/protocol"
+)
+
+type step struct {
+	offset time.Duration
+	expect *protocol.Frame
+	send   []byte
+}
+
+type Divergence struct {
+	Step     int
+	Expected string
+	Actual   string
+}
+
+type Report struct {
+	Expected    int
+	Matched     int
+	Divergences []Divergence
+}
+
+func (r *Report) OK() bool {
+	return len(r.Divergences) == 0 && r.Matched == r.Expected
+}
+
+// Replayer plays the server side of the capture and compares frames sent by the client with the captured ones
+type Replayer struct {
+	steps []step
+	// Ignore commands are answered with OK and not compared, pings depend on timing
+	Ignore          map[protocol.Command]bool
+	IgnoreMessageID bool
+	// KeepTiming delays server frames by their captured offsets
+	KeepTiming bool
+	Timeout    time.Duration
+	// TailWait is how long frames sent by the client after the end of the script are waited for
+	TailWait time.Duration
+}
+
+func NewReplayer(records []Record) (*Replayer, error) {
+	r := &Replayer{
+		Ignore:     map[protocol.Command]bool{protocol.CMD_PING: true},
+		KeepTiming: true,
+		Timeout:    time.Second * 5,
+		TailWait:   time.Millisecond * 500,
+	}
+
+	var pending []byte
+	for _, rec := range records {
+		buf, err := rec.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		switch rec.Direction {
+		case DIR_SEND:
+			pending = append(pending, buf...)
+			for {
+				f, n, err := protocol.DecodeFrame(pending)
+				if err != nil {
+					break
+				}
+				body := make([]byte, len(f.Body))
+				copy(body, f.Body)
+				f.Body = body
+				r.steps = append(r.steps, step{offset: rec.Offset, expect: f})
+				pending = pending[n:]
+			}
+		case DIR_RECV:
+			r.steps = append(r.steps, step{offset: rec.Offset, send: buf})
+		default:
+			return nil, fmt.Errorf("capture: unknown direction %q", rec.Direction)
+		}
+	}
+	return r, nil
+}
+
+// Serve replays the capture on conn, the connection is closed when the script is over
+func (r *Replayer) Serve(conn net.Conn) *Report {
+	defer conn.Close()
+	report := &Report{}
+	dec := protocol.NewDecoder(conn)
+	enc := protocol.NewEncoder(conn)
+	ignored := r.ignoredIDs()
+	start := time.Now()
+
+	for i, s := range r.steps {
+		if s.expect != nil {
+			if r.Ignore[s.expect.Command] {
+				continue
+			}
+			report.Expected++
+			actual, err := r.next(conn, dec, enc, r.Timeout)
+			if err != nil {
+				report.Divergences = append(report.Divergences, Divergence{Step: i, Expected: describe(s.expect), Actual: err.Error()})
+				return report
+			}
+			if r.equal(s.expect, actual) {
+				report.Matched++
+			} else {
+				report.Divergences = append(report.Divergences, Divergence{Step: i, Expected: describe(s.expect), Actual: describe(actual)})
+			}
+			continue
+		}
+
+		if r.KeepTiming {
+			if d := s.offset - time.Since(start); d > 0 {
+				time.Sleep(d)
+			}
+		}
+		if _, err := conn.Write(r.filter(s.send, ignored)); err != nil {
+			report.Divergences = append(report.Divergences, Divergence{Step: i, Expected: "server write", Actual: err.Error()})
+			return report
+		}
+	}
+
+	//frames after the end of the script are new traffic of the client
+	for {
+		actual, err := r.next(conn, dec, enc, r.TailWait)
+		if err != nil {
+			return report
+		}
+		report.Divergences = append(report.Divergences, Divergence{Step: len(r.steps), Expected: "end of capture", Actual: describe(actual)})
+	}
+}
+
+// ServeListener accepts one client and replays the capture
+func (r *Replayer) ServeListener(ln net.Listener) (*Report, error) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return r.Serve(conn), nil
+}
+
+// next reads the next not ignored frame, ignored frames are answered with OK
+func (r *Replayer) next(conn net.Conn, dec *protocol.Decoder, enc *protocol.Encoder, timeout time.Duration) (*protocol.Frame, error) {
+	for {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		f, err := dec.Decode()
+		if err != nil {
+			return nil, err
+		}
+		if !r.Ignore[f.Command] {
+			return f, nil
+		}
+		if err := enc.Encode(&protocol.Frame{Command: protocol.CMD_RESPONSE, MessageId: f.MessageId, Length: protocol.STATUS_SUCCESS}); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ignoredIDs collects message ids of ignored client frames, captured responses to them are dropped
+func (r *Replayer) ignoredIDs() map[uint16]bool {
+	ids := make(map[uint16]bool)
+	for _, s := range r.steps {
+		if s.expect != nil && r.Ignore[s.expect.Command] {
+			ids[s.expect.MessageId] = true
+		}
+	}
+	return ids
+}
+
+func (r *Replayer) filter(buf []byte, ignored map[uint16]bool) []byte {
+	var out []byte
+	for len(buf) > 0 {
+		f, n, err := protocol.DecodeFrame(buf)
+		if err != nil {
+			return append(out, buf...)
+		}
+		if !(f.Command == protocol.CMD_RESPONSE && ignored[f.MessageId]) {
+			out = append(out, buf[:n]...)
+		}
+		buf = buf[n:]
+	}
+	return out
+}
+
+func (r *Replayer) equal(expected *protocol.Frame, actual *protocol.Frame) bool {
+	if expected.Command != actual.Command || expected.Length != actual.Length {
+		return false
+	}
+	if !r.IgnoreMessageID && expected.MessageId != actual.MessageId {
+		return false
+	}
+	if isLogin(actual.Command) {
+		//login is redacted by the recorder, older captures may keep it in plain text
+		return redacted(expected.Body) == redacted(actual.Body)
+	}
+	return string(expected.Body) == string(actual.Body)
+}
+
+func redacted(body []byte) string {
+	body = append([]byte(nil), body...)
+	redactFields(body)
+	return string(body)
+}
+
+func describe(f *protocol.Frame) string {
+	body := string(f.Body)
+	if isLogin(f.Command) {
+		body = redacted(f.Body)
+	}
+	return fmt.Sprintf("%s id=%d len=%d [%s]", f.Command, f.MessageId, f.Length, strings.ReplaceAll(body, "\x00", " | "))
+}
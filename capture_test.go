@@ -0,0 +1,96 @@
+package blynk
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OloloevReal/go-blynk/capture"
+	"github.com/OloloevReal/go-blynk/server"
+)
+
+// captureSession logs in, writes value to V1 and sends a notification, the server rejects notifications
+func captureSession(t *testing.T, port int, rec *capture.Recorder, value string) {
+	t.Helper()
+	g := NewBlynk("lamp")
+	g.DisableLogo(true)
+	g.SetServer("127.0.0.1", port, false)
+	g.SetRecorder(rec)
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Disconnect()
+	if err := g.VirtualWrite(1, value); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Notify("door is open"); !errors.Is(err, ErrIllegalCommand) {
+		t.Fatalf("notify: %v, want ErrIllegalCommand", err)
+	}
+}
+
+func replaySession(t *testing.T, records []capture.Record, value string) *capture.Report {
+	t.Helper()
+	r, err := capture.NewReplayer(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.KeepTiming = false
+	r.Timeout = time.Second
+	r.TailWait = 100 * time.Millisecond
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	reports := make(chan *capture.Report, 1)
+	go func() {
+		report, err := r.ServeListener(ln)
+		if err != nil {
+			t.Error(err)
+		}
+		reports <- report
+	}()
+	captureSession(t, ln.Addr().(*net.TCPAddr).Port, nil, value)
+	return <-reports
+}
+
+func TestRecordReplayRoundTrip(t *testing.T) {
+	store := server.NewStore()
+	store.AddDevice(server.Device{Token: "lamp"})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.NewServer(store)
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	rec := capture.NewRecorder(&buf)
+	captureSession(t, ln.Addr().(*net.TCPAddr).Port, rec, "42")
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "6c616d70") {
+		t.Fatalf("capture keeps the token:\n%s", buf.String())
+	}
+	records, err := capture.ReadRecords(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//login, internal, hardware and notify
+	report := replaySession(t, records, "42")
+	if !report.OK() || report.Matched != 4 {
+		t.Fatalf("replay of the same session: %+v", report)
+	}
+
+	report = replaySession(t, records, "43")
+	if report.OK() || len(report.Divergences) != 1 || !strings.Contains(report.Divergences[0].Actual, "vw | 1 | 43") {
+		t.Fatalf("replay of changed session: %+v", report)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	blynk "github.com/OloloevReal/go-blynk"
+	"github.com/OloloevReal/go-blynk/mqtt"
+	slog "github.com/OloloevReal/go-simple-log"
+)
+
+type config struct {
+	device   string
+	pins     []uint
+	topicOut string
+	topicIn  string
+	qos      byte
+	retain   bool
+}
+
+// mqttClient is the part of mqtt.Client used by the bridge
+type mqttClient interface {
+	Publish(topic string, payload []byte, qos byte, retain bool) error
+	Subscribe(filter string, qos byte, fn func(*mqtt.Message)) error
+	Done() <-chan struct{}
+}
+
+type bridge struct {
+	app    *blynk.Blynk
+	client mqttClient
+	cfg    config
+	lock   sync.Mutex
+	values map[uint]string
+	states map[uint]string
+	wake   chan struct{}
+}
+
+func newBridge(app *blynk.Blynk, client mqttClient, cfg config) *bridge {
+	return &bridge{
+		app:    app,
+		client: client,
+		cfg:    cfg,
+		values: make(map[uint]string),
+		states: make(map[uint]string),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// start binds app writes of the pins to MQTT publishes and MQTT messages to VirtualWrite
+func (b *bridge) start() error {
+	for _, pin := range b.cfg.pins {
+		b.app.AddWriterHandler(pin, b.onPinWrite)
+		b.app.AddReaderHandler(pin, b.onPinRead)
+	}
+
+	if b.cfg.topicIn == "" {
+		return nil
+	}
+	go b.publishStates()
+	return b.client.Subscribe(topicFilter(b.cfg.topicIn, b.cfg.device), b.cfg.qos, b.onMessage)
+}
+
+func (b *bridge) onPinWrite(pin uint, reader io.Reader) {
+	value, err := io.ReadAll(reader)
+	if err != nil {
+		slog.Printf("[ERROR] bridge: read pin %d failed, %s", pin, err.Error())
+		return
+	}
+	b.setValue(pin, string(value))
+	b.publish(pin, string(value))
+}
+
+func (b *bridge) publish(pin uint, value string) {
+	topic := expandTopic(b.cfg.topicOut, b.cfg.device, pin)
+	if err := b.client.Publish(topic, []byte(value), b.cfg.qos, b.cfg.retain); err != nil {
+		slog.Printf("[ERROR] bridge: publish to %s failed, %s", topic, err.Error())
+	}
+}
+
+func (b *bridge) onPinRead(pin uint, writer io.Writer) {
+	b.lock.Lock()
+	value := b.values[pin]
+	b.lock.Unlock()
+	io.WriteString(writer, value)
+}
+
+func (b *bridge) onMessage(m *mqtt.Message) {
+	pin, ok := topicPin(b.cfg.topicIn, b.cfg.device, m.Topic)
+	if !ok || !b.bound(pin) {
+		slog.Printf("[DEBUG] bridge: topic %s is not bound to a pin", m.Topic)
+		return
+	}
+	b.setValue(pin, string(m.Payload))
+	if err := b.app.VirtualWrite(int(pin), string(m.Payload)); err != nil {
+		slog.Printf("[ERROR] bridge: write to pin %d failed, %s", pin, err.Error())
+		return
+	}
+	b.queueState(pin, string(m.Payload))
+}
+
+// queueState schedules the state publish of the written pin, subscribers like Home Assistant
+// wait for it to confirm the command. Messages are dispatched on the MQTT reader goroutine,
+// a publish there would wait for its own PUBACK, so states are published by publishStates.
+func (b *bridge) queueState(pin uint, value string) {
+	b.lock.Lock()
+	b.states[pin] = value
+	b.lock.Unlock()
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// publishStates publishes queued states until the MQTT connection is closed, only the last state of a pin is kept
+func (b *bridge) publishStates() {
+	for {
+		select {
+		case <-b.wake:
+		case <-b.client.Done():
+			return
+		}
+		b.lock.Lock()
+		states := b.states
+		b.states = make(map[uint]string)
+		b.lock.Unlock()
+		for pin, value := range states {
+			b.publish(pin, value)
+		}
+	}
+}
+
+func (b *bridge) setValue(pin uint, value string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.values[pin] = value
+}
+
+func (b *bridge) bound(pin uint) bool {
+	for _, p := range b.cfg.pins {
+		if p == pin {
+			return true
+		}
+	}
+	return false
+}
+
+// expandTopic replaces {device} and {pin} placeholders of the template
+func expandTopic(template string, device string, pin uint) string {
+	return strings.NewReplacer("{device}", device, "{pin}", strconv.FormatUint(uint64(pin), 10)).Replace(template)
+}
+
+// topicFilter turns the template to a subscription filter, the level with {pin} becomes +
+func topicFilter(template string, device string) string {
+	levels := strings.Split(strings.ReplaceAll(template, "{device}", device), "/")
+	for i, level := range levels {
+		if strings.Contains(level, "{pin}") {
+			levels[i] = "+"
+		}
+	}
+	return strings.Join(levels, "/")
+}
+
+func topicPin(template string, device string, topic string) (uint, bool) {
+	levels := strings.Split(strings.ReplaceAll(template, "{device}", device), "/")
+	topicLevels := strings.Split(topic, "/")
+	if len(levels) != len(topicLevels) {
+		return 0, false
+	}
+	for i, level := range levels {
+		idx := strings.Index(level, "{pin}")
+		if idx < 0 {
+			if level != topicLevels[i] {
+				return 0, false
+			}
+			continue
+		}
+		prefix, suffix := level[:idx], level[idx+len("{pin}"):]
+		value := topicLevels[i]
+		if !strings.HasPrefix(value, prefix) || !strings.HasSuffix(value, suffix) || len(value) < len(prefix)+len(suffix) {
+			return 0, false
+		}
+		pin, err := strconv.ParseUint(value[len(prefix):len(value)-len(suffix)], 10, 32)
+		if err != nil {
+			return 0, false
+		}
+		return uint(pin), true
+	}
+	return 0, false
+}
+
+func parseQoS(n int) (byte, error) {
+	if n != 0 && n != 1 {
+		return 0, fmt.Errorf("bad qos %d, 0 or 1 is supported", n)
+	}
+	return byte(n), nil
+}
+
+func parsePins(s string) ([]uint, error) {
+	var pins []uint
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimPrefix(strings.TrimSpace(p), "V")
+		if p == "" {
+			continue
+		}
+		pin, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad pin %q", p)
+		}
+		pins = append(pins, uint(pin))
+	}
+	return pins, nil
+}
+
+// pinMeta is an entry of the -meta file
+type pinMeta struct {
+	Name        string  `json:"name"`
+	Unit        string  `json:"unit"`
+	Widget      string  `json:"widget"`
+	DeviceClass string  `json:"device_class"`
+	Icon        string  `json:"icon"`
+	Min         float64 `json:"min"`
+	Max         float64 `json:"max"`
+	Step        float64 `json:"step"`
+}
+
+// loadPinMeta reads pin metadata from a JSON object keyed by pin:
+//
+//	{"V5": {"name": "Lamp", "widget": "switch"}, "V6": {"name": "Temperature", "unit": "°C", "device_class": "temperature"}}
+func loadPinMeta(path string) (map[uint]blynk.PinMeta, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries map[string]pinMeta
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("meta: bad file %s, %s", path, err.Error())
+	}
+
+	metas := make(map[uint]blynk.PinMeta, len(entries))
+	for key, e := range entries {
+		pins, err := parsePins(key)
+		if err != nil || len(pins) != 1 {
+			return nil, fmt.Errorf("meta: bad pin %q", key)
+		}
+		metas[pins[0]] = blynk.PinMeta{
+			Name:        e.Name,
+			Unit:        e.Unit,
+			Widget:      e.Widget,
+			DeviceClass: e.DeviceClass,
+			Icon:        e.Icon,
+			Min:         e.Min,
+			Max:         e.Max,
+			Step:        e.Step,
+		}
+	}
+	return metas, nil
+}
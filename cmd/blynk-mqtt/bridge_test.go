@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	blynk "github.com/OloloevReal/go-blynk"
+	"github.com/OloloevReal/go-blynk/mqtt"
+	"github.com/OloloevReal/go-blynk/server"
+)
+
+// fakeClient records publishes and keeps the subscription handler
+type fakeClient struct {
+	published chan *mqtt.Message
+	fn        func(*mqtt.Message)
+	done      chan struct{}
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{published: make(chan *mqtt.Message, 8), done: make(chan struct{})}
+}
+
+func (c *fakeClient) Publish(topic string, payload []byte, qos byte, retain bool) error {
+	c.published <- &mqtt.Message{Topic: topic, Payload: payload, QoS: qos, Retain: retain}
+	return nil
+}
+
+func (c *fakeClient) Subscribe(filter string, qos byte, fn func(*mqtt.Message)) error {
+	c.fn = fn
+	return nil
+}
+
+func (c *fakeClient) Done() <-chan struct{} {
+	return c.done
+}
+
+func testConfig() config {
+	return config{device: "dev", pins: []uint{5}, topicOut: "blynk/{device}/V{pin}", topicIn: "blynk/{device}/V{pin}/set", retain: true}
+}
+
+// connectDevice logs in to an in-process server
+func connectDevice(t *testing.T) *blynk.Blynk {
+	t.Helper()
+	store, err := server.OpenStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.AddDevice(server.Device{Token: "dev", Dashboard: "home"})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.NewServer(store)
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	app := blynk.NewBlynk("dev")
+	app.DisableLogo(true)
+	app.SetServer("127.0.0.1", ln.Addr().(*net.TCPAddr).Port, false)
+	if err := app.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	go app.Processing()
+	t.Cleanup(func() { app.Stop() })
+	return app
+}
+
+func TestBridgePublishesStateAfterSet(t *testing.T) {
+	client := newFakeClient()
+	defer close(client.done)
+	b := newBridge(connectDevice(t), client, testConfig())
+	if err := b.start(); err != nil {
+		t.Fatal(err)
+	}
+
+	client.fn(&mqtt.Message{Topic: "blynk/dev/V5/set", Payload: []byte("1")})
+	select {
+	case m := <-client.published:
+		if m.Topic != "blynk/dev/V5" || string(m.Payload) != "1" || !m.Retain {
+			t.Fatalf("published %s %q retain %v", m.Topic, m.Payload, m.Retain)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("state is not published")
+	}
+}
+
+func TestBridgeSkipsStateOfFailedSet(t *testing.T) {
+	client := newFakeClient()
+	defer close(client.done)
+	b := newBridge(blynk.NewBlynk("dev"), client, testConfig())
+	if err := b.start(); err != nil {
+		t.Fatal(err)
+	}
+
+	client.fn(&mqtt.Message{Topic: "blynk/dev/V5/set", Payload: []byte("1")})
+	select {
+	case m := <-client.published:
+		t.Fatalf("published %s %q without a connection", m.Topic, m.Payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestLoadPinMeta(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meta.json")
+	data := `{"V5": {"name": "Lamp", "widget": "switch"}, "7": {"name": "Level", "widget": "number", "min": 0, "max": 100, "step": 5}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	metas, err := loadPinMeta(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint]blynk.PinMeta{
+		5: {Name: "Lamp", Widget: blynk.PIN_WIDGET_SWITCH},
+		7: {Name: "Level", Widget: blynk.PIN_WIDGET_NUMBER, Max: 100, Step: 5},
+	}
+	if !reflect.DeepEqual(metas, want) {
+		t.Fatalf("meta %+v, want %+v", metas, want)
+	}
+	if pins := addPins([]uint{7, 1}, metas); !reflect.DeepEqual(pins, []uint{7, 1, 5}) {
+		t.Fatalf("pins %v", pins)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"X1": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadPinMeta(path); err == nil {
+		t.Fatal("bad pin is accepted")
+	}
+}
+
+func TestParseQoS(t *testing.T) {
+	for _, n := range []int{0, 1} {
+		if qos, err := parseQoS(n); err != nil || qos != byte(n) {
+			t.Fatalf("qos %d: %d, %v", n, qos, err)
+		}
+	}
+	for _, n := range []int{-1, 2, 256} {
+		if _, err := parseQoS(n); err == nil {
+			t.Fatalf("qos %d is accepted", n)
+		}
+	}
+}
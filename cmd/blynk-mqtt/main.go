@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	blynk "github.com/OloloevReal/go-blynk"
+	"github.com/OloloevReal/go-blynk/hass"
+	"github.com/OloloevReal/go-blynk/mqtt"
+	slog "github.com/OloloevReal/go-simple-log"
+)
+
+func main() {
+	slog.Printf("Blynk MQTT bridge starting, version %s", blynk.Version)
+	defer slog.Println("Blynk MQTT bridge finished")
+
+	auth := flag.String("auth", os.Getenv("BLYNK_TOKEN"), "set -auth=blynk_token")
+	server := flag.String("server", "blynk-cloud.com", "blynk server")
+	port := flag.Int("port", 443, "blynk server port")
+	ssl := flag.Bool("ssl", true, "use TLS for blynk server")
+	broker := flag.String("mqtt", "127.0.0.1:1883", "mqtt broker address")
+	clientID := flag.String("client-id", "blynk-mqtt", "mqtt client id")
+	user := flag.String("user", os.Getenv("MQTT_USER"), "mqtt user")
+	password := flag.String("password", os.Getenv("MQTT_PASSWORD"), "mqtt password")
+	device := flag.String("device", "device", "device name for {device} in topics")
+	pins := flag.String("pins", "", "virtual pins to bridge, e.g. -pins=V1,V2,5")
+	topicOut := flag.String("topic-out", "blynk/{device}/V{pin}", "topic for pin writes from the app")
+	topicIn := flag.String("topic-in", "blynk/{device}/V{pin}/set", "topic for writes to pins, empty disables")
+	qos := flag.Int("qos", 0, "mqtt qos, 0 or 1")
+	retain := flag.Bool("retain", true, "publish pin values as retained")
+	discovery := flag.String("discovery", "", "Home Assistant discovery prefix, e.g. homeassistant, empty disables")
+	meta := flag.String("meta", "", "json file with pin metadata for discovery, its pins are bridged too")
+	debug := flag.Bool("debug", false, "debug logging")
+	flag.Parse()
+
+	if *debug {
+		slog.SetOptions(slog.SetDebug)
+	}
+
+	cfg := config{
+		device:   *device,
+		topicOut: *topicOut,
+		topicIn:  *topicIn,
+		retain:   *retain,
+	}
+	var err error
+	if cfg.qos, err = parseQoS(*qos); err != nil {
+		slog.Fatalln(err)
+	}
+	if cfg.pins, err = parsePins(*pins); err != nil {
+		slog.Fatalln(err)
+	}
+	var metas map[uint]blynk.PinMeta
+	if *meta != "" {
+		if metas, err = loadPinMeta(*meta); err != nil {
+			slog.Fatalln(err)
+		}
+		cfg.pins = addPins(cfg.pins, metas)
+	}
+
+	client, err := mqtt.Dial(*broker, mqtt.Options{
+		ClientID:     *clientID,
+		Username:     *user,
+		Password:     *password,
+		KeepAlive:    time.Second * 30,
+		CleanSession: true,
+	})
+	if err != nil {
+		slog.Fatalln(err)
+	}
+	defer client.Close()
+
+	app := blynk.NewBlynk(*auth)
+	app.SetServer(*server, *port, *ssl)
+	if *debug {
+		app.SetDebug()
+	}
+	for pin, m := range metas {
+		app.SetPinMeta(pin, m)
+	}
+
+	b := newBridge(app, client, cfg)
+	if err := b.start(); err != nil {
+		slog.Fatalln(err)
+	}
+
+	if *discovery != "" {
+		if err := publishDiscovery(app, client, cfg, *discovery); err != nil {
+			slog.Fatalln(err)
+		}
+	}
+
+	if err := app.Connect(); err != nil {
+		slog.Fatalln(err)
+	}
+
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		select {
+		case <-stop:
+			slog.Printf("[INFO] interrupt signal")
+		case <-client.Done():
+			slog.Printf("[ERROR] mqtt connection lost, %v", client.Err())
+		}
+		app.Stop()
+	}()
+
+	app.Processing()
+}
+
+// publishDiscovery announces bridged pins to Home Assistant, pins without metadata are sensors
+func publishDiscovery(app *blynk.Blynk, client *mqtt.Client, cfg config, prefix string) error {
+	for _, pin := range cfg.pins {
+		if _, ok := app.PinMeta(pin); !ok {
+			app.SetPinMeta(pin, blynk.PinMeta{Widget: blynk.PIN_WIDGET_SENSOR})
+		}
+	}
+	hc := hass.DefaultConfig(cfg.device)
+	hc.Prefix = prefix
+	hc.StateTopic = cfg.topicOut
+	hc.CommandTopic = cfg.topicIn
+	hc.QoS = cfg.qos
+	return hass.Publish(client, hc, app)
+}
+
+// addPins appends pins of the metadata which are not bridged yet, in pin order
+func addPins(pins []uint, metas map[uint]blynk.PinMeta) []uint {
+	bridged := make(map[uint]bool, len(pins))
+	for _, pin := range pins {
+		bridged[pin] = true
+	}
+	var extra []uint
+	for pin := range metas {
+		if !bridged[pin] {
+			extra = append(extra, pin)
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i] < extra[j] })
+	return append(pins, extra...)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	certs "github.com/OloloevReal/go-blynk/certs"
+	"github.com/OloloevReal/go-blynk/protocol"
+	slog "github.com/OloloevReal/go-simple-log"
+)
+
+type frameLine struct {
+	Time      time.Time `json:"time"`
+	Session   int       `json:"session"`
+	Direction string    `json:"direction"`
+	Command   string    `json:"command"`
+	MessageId uint16    `json:"message_id"`
+	Status    string    `json:"status,omitempty"`
+	Length    uint16    `json:"length"`
+	Values    []string  `json:"values,omitempty"`
+}
+
+type printer struct {
+	lock   sync.Mutex
+	out    io.Writer
+	asJSON bool
+}
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:8080", "local address for devices")
+	upstream := flag.String("upstream", "blynk-cloud.com:443", "blynk server address")
+	upstreamTLS := flag.Bool("upstream-tls", true, "connect to the server with TLS")
+	insecure := flag.Bool("insecure", false, "skip verification of the server certificate")
+	certFile := flag.String("cert", "", "certificate for TLS on the local side")
+	keyFile := flag.String("key", "", "key for TLS on the local side")
+	format := flag.String("format", "text", "output format: text or json")
+	flag.Parse()
+
+	p := &printer{out: os.Stdout, asJSON: *format == "json"}
+
+	var ln net.Listener
+	var err error
+	if *certFile != "" {
+		cert, certErr := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if certErr != nil {
+			slog.Fatalln(certErr)
+		}
+		ln, err = tls.Listen("tcp", *listen, &tls.Config{Certificates: []tls.Certificate{cert}})
+	} else {
+		ln, err = net.Listen("tcp", *listen)
+	}
+	if err != nil {
+		slog.Fatalln(err)
+	}
+	slog.Printf("Blynk proxy: listen %s, upstream %s (TLS: %v)", ln.Addr(), *upstream, *upstreamTLS)
+
+	dial := func() (net.Conn, error) {
+		if !*upstreamTLS {
+			return net.Dial("tcp", *upstream)
+		}
+		return tls.Dial("tcp", *upstream, upstreamTLSConfig(*upstream, *insecure))
+	}
+
+	for session := 1; ; session++ {
+		conn, err := ln.Accept()
+		if err != nil {
+			slog.Fatalln(err)
+		}
+		go proxy(session, conn, dial, p)
+	}
+}
+
+func upstreamTLSConfig(addr string, insecure bool) *tls.Config {
+	host, _, _ := net.SplitHostPort(addr)
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM([]byte(certs.CertServer))
+	return &tls.Config{
+		ServerName:         host,
+		RootCAs:            roots,
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure,
+	}
+}
+
+func proxy(session int, device net.Conn, dial func() (net.Conn, error), p *printer) {
+	defer device.Close()
+	slog.Printf("[INFO] session %d: device %s connected", session, device.RemoteAddr())
+
+	server, err := dial()
+	if err != nil {
+		slog.Printf("[ERROR] session %d: dial upstream failed, %s", session, err.Error())
+		return
+	}
+	defer server.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		pipe(session, ">>", device, server, p)
+		done <- struct{}{}
+	}()
+	go func() {
+		pipe(session, "<<", server, device, p)
+		done <- struct{}{}
+	}()
+	<-done
+	slog.Printf("[INFO] session %d: closed", session)
+}
+
+// pipe decodes frames from src, prints and forwards them to dst
+func pipe(session int, direction string, src net.Conn, dst net.Conn, p *printer) {
+	dec := protocol.NewDecoder(src)
+	enc := protocol.NewEncoder(dst)
+	for {
+		f, err := dec.Decode()
+		if err != nil {
+			if err != io.EOF {
+				slog.Printf("[ERROR] session %d %s: %s", session, direction, err.Error())
+			}
+			return
+		}
+		p.print(session, direction, f)
+		if err := enc.Encode(f); err != nil {
+			slog.Printf("[ERROR] session %d %s: forward failed, %s", session, direction, err.Error())
+			return
+		}
+	}
+}
+
+func (p *printer) print(session int, direction string, f *protocol.Frame) {
+	line := frameLine{
+		Time:      time.Now(),
+		Session:   session,
+		Direction: direction,
+		Command:   f.Command.String(),
+		MessageId: f.MessageId,
+		Length:    f.Length,
+		Values:    f.Values(),
+	}
+	if f.Command == protocol.CMD_RESPONSE {
+		line.Status = protocol.StatusText(f.Length)
+	}
+	switch f.Command {
+	case protocol.CMD_HW_LOGIN, protocol.CMD_LOGIN, protocol.CMD_SHARE_LOGIN, protocol.CMD_REGISTER:
+		line.Values = redact(line.Values)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.asJSON {
+		json.NewEncoder(p.out).Encode(line)
+		return
+	}
+	fmt.Fprintf(p.out, "%s #%d %s %-13s id=%-5d len=%-5d", line.Time.Format("15:04:05.000"), session, direction, line.Command, line.MessageId, line.Length)
+	if line.Status != "" {
+		fmt.Fprintf(p.out, " status=%s", line.Status)
+	}
+	if len(line.Values) > 0 {
+		fmt.Fprintf(p.out, " %s", strings.Join(line.Values, " | "))
+	}
+	fmt.Fprintln(p.out)
+}
+
+// redact keeps only the first 4 chars of tokens and passwords, shorter values are hidden completely
+func redact(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		if len(v) > 8 {
+			v = v[:4] + strings.Repeat("*", len(v)-4)
+		} else {
+			v = strings.Repeat("*", len(v))
+		}
+		out[i] = v
+	}
+	return out
+}
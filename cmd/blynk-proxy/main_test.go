@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/OloloevReal/go-blynk/protocol"
+)
+
+const testToken = "0123456789abcdef0123456789abcdef"
+
+func frame(t *testing.T, cmd protocol.Command, id uint16, values ...string) *protocol.Frame {
+	t.Helper()
+	f, err := protocol.NewFrame(cmd, id, values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestRedact(t *testing.T) {
+	got := redact([]string{testToken, "short", "", "123456789"})
+	want := []string{"0123" + strings.Repeat("*", len(testToken)-4), "*****", "", "1234*****"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("redact = %q, want %q", got, want)
+	}
+}
+
+func TestPrintRedactsLogin(t *testing.T) {
+	for _, cmd := range []protocol.Command{protocol.CMD_HW_LOGIN, protocol.CMD_LOGIN, protocol.CMD_SHARE_LOGIN, protocol.CMD_REGISTER} {
+		for _, asJSON := range []bool{false, true} {
+			var out bytes.Buffer
+			p := &printer{out: &out, asJSON: asJSON}
+			p.print(1, ">>", frame(t, cmd, 1, testToken))
+			if strings.Contains(out.String(), testToken[4:]) {
+				t.Fatalf("%s (json: %v) prints the token: %s", cmd, asJSON, out.String())
+			}
+			if !strings.Contains(out.String(), "0123****") {
+				t.Fatalf("%s (json: %v) hides the token prefix: %s", cmd, asJSON, out.String())
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	p := &printer{out: &out}
+	p.print(1, ">>", frame(t, protocol.CMD_HARDWARE, 2, "vw", "5", testToken))
+	if !strings.Contains(out.String(), testToken) {
+		t.Fatalf("hardware values are redacted: %s", out.String())
+	}
+}
+
+func TestPrintText(t *testing.T) {
+	var out bytes.Buffer
+	p := &printer{out: &out}
+	p.print(3, "<<", frame(t, protocol.CMD_HARDWARE, 7, "vw", "5", "12"))
+	p.print(3, ">>", &protocol.Frame{Command: protocol.CMD_RESPONSE, MessageId: 7, Length: protocol.STATUS_INVALID_TOKEN})
+	p.print(3, ">>", frame(t, protocol.Command(99), 8))
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines: %q", len(lines), out.String())
+	}
+	for i, want := range []string{
+		"#3 << HARDWARE      id=7     len=7     vw | 5 | 12",
+		"#3 >> RESPONSE      id=7     len=9     status=" + protocol.StatusText(protocol.STATUS_INVALID_TOKEN),
+		"#3 >> CMD_99        id=8     len=0",
+	} {
+		// the line starts with the time
+		if got := strings.TrimRight(lines[i][13:], " "); got != want {
+			t.Errorf("line %d: %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestPrintJSON(t *testing.T) {
+	var out bytes.Buffer
+	p := &printer{out: &out, asJSON: true}
+	p.print(2, ">>", &protocol.Frame{Command: protocol.CMD_RESPONSE, MessageId: 4, Length: protocol.STATUS_SUCCESS})
+
+	var line frameLine
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	want := frameLine{Session: 2, Direction: ">>", Command: "RESPONSE", MessageId: 4, Status: protocol.StatusText(protocol.STATUS_SUCCESS), Length: protocol.STATUS_SUCCESS}
+	line.Time = want.Time
+	if !reflect.DeepEqual(line, want) {
+		t.Fatalf("json line %+v, want %+v", line, want)
+	}
+}
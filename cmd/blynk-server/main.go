@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	blynk "github.com/OloloevReal/go-blynk"
+	"github.com/OloloevReal/go-blynk/server"
+	slog "github.com/OloloevReal/go-simple-log"
+)
+
+func main() {
+	listen := flag.String("listen", ":8080", "address for plain TCP devices, empty disables")
+	listenTLS := flag.String("listen-tls", ":9443", "address for TLS devices, used with -cert and -key")
+	listenHTTP := flag.String("listen-http", "", "address for WebSocket devices on /websocket, empty disables")
+	certFile := flag.String("cert", "", "server certificate")
+	keyFile := flag.String("key", "", "server key")
+	statePath := flag.String("state", "blynk-server.json", "file with tokens and pin values")
+	save := flag.Duration("save", time.Second*10, "interval of state saving")
+	add := flag.String("add", "", "add devices, e.g. -add=token1:dashboard:name,token2:dashboard")
+	debug := flag.Bool("debug", false, "debug logging")
+	flag.Parse()
+
+	if *debug {
+		slog.SetOptions(slog.SetDebug)
+	}
+	slog.Printf("Blynk server starting, version %s", blynk.Version)
+
+	store, err := server.OpenStore(*statePath)
+	if err != nil {
+		slog.Fatalln(err)
+	}
+	if err := addDevices(store, *add); err != nil {
+		slog.Fatalln(err)
+	}
+	if err := store.Save(); err != nil {
+		slog.Fatalln(err)
+	}
+
+	srv := server.NewServer(store)
+	srv.SetSaveInterval(*save)
+
+	errs := make(chan error, 3)
+	if *listen != "" {
+		go func() { errs <- srv.ListenAndServe(*listen) }()
+	}
+	if *listenHTTP != "" {
+		mux := http.NewServeMux()
+		mux.Handle(blynk.BLYNK_WEBSOCKET_PATH, srv)
+		go func() { errs <- http.ListenAndServe(*listenHTTP, mux) }()
+	}
+	if *certFile != "" {
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			slog.Fatalln(err)
+		}
+		ln, err := tls.Listen("tcp", *listenTLS, &tls.Config{Certificates: []tls.Certificate{cert}})
+		if err != nil {
+			slog.Fatalln(err)
+		}
+		go func() { errs <- srv.Serve(ln) }()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-stop:
+		slog.Printf("[INFO] interrupt signal")
+	case err := <-errs:
+		slog.Printf("[ERROR] %s", err.Error())
+	}
+	if err := srv.Close(); err != nil {
+		slog.Printf("[ERROR] close failed, %s", err.Error())
+	}
+	slog.Println("Blynk server finished")
+}
+
+// addDevices parses token:dashboard:name list, existing tokens are skipped
+func addDevices(store *server.Store, s string) error {
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.SplitN(item, ":", 3)
+		d := server.Device{Token: parts[0]}
+		if len(parts) > 1 {
+			d.Dashboard = parts[1]
+		}
+		if len(parts) > 2 {
+			d.Name = parts[2]
+		}
+		if d.Token == "" {
+			return fmt.Errorf("bad device %q", item)
+		}
+		if _, ok := store.Device(d.Token); ok {
+			continue
+		}
+		if err := store.AddDevice(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
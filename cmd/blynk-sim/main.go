@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	blynk "github.com/OloloevReal/go-blynk"
+	slog "github.com/OloloevReal/go-simple-log"
+)
+
+type config struct {
+	rate        float64
+	ackEvery    int
+	pins        []int
+	readPins    []uint
+	readLatency time.Duration
+}
+
+func main() {
+	tokensFile := flag.String("tokens", "tokens.txt", "file with device tokens, one per line")
+	count := flag.Int("n", 0, "number of simulated devices, 0 for all tokens of the file")
+	server := flag.String("server", "127.0.0.1", "blynk server")
+	port := flag.Int("port", 8080, "blynk server port")
+	ssl := flag.Bool("ssl", false, "use TLS")
+	duration := flag.Duration("duration", time.Minute, "duration of the test")
+	ramp := flag.Duration("ramp", time.Millisecond*10, "delay between device connects")
+	rate := flag.Float64("rate", 1, "virtual writes per second of every device, 0 disables writes")
+	ackEvery := flag.Int("ack-every", 10, "ping after every N writes to measure the write ack, 0 disables")
+	pins := flag.String("pins", "V1", "virtual pins for writes, e.g. -pins=V1,V2")
+	readPins := flag.String("read-pins", "", "virtual pins answered by reader handlers")
+	readLatency := flag.Duration("read-latency", 0, "latency of reader handlers")
+	debug := flag.Bool("debug", false, "debug logging")
+	flag.Parse()
+
+	if *debug {
+		slog.SetOptions(slog.SetDebug)
+	}
+
+	tokens, err := readTokens(*tokensFile)
+	if err != nil {
+		slog.Fatalln(err)
+	}
+	if *count > len(tokens) {
+		slog.Fatalf("%d devices requested, %d tokens in %s", *count, len(tokens), *tokensFile)
+	}
+	if *count > 0 {
+		tokens = tokens[:*count]
+	}
+
+	cfg := config{rate: *rate, ackEvery: *ackEvery, readLatency: *readLatency}
+	writePins, err := parsePins(*pins)
+	if err != nil {
+		slog.Fatalln(err)
+	}
+	for _, pin := range writePins {
+		cfg.pins = append(cfg.pins, int(pin))
+	}
+	if cfg.readPins, err = parsePins(*readPins); err != nil {
+		slog.Fatalln(err)
+	}
+
+	st := newStats()
+	m := blynk.NewManager()
+	m.SetServer(*server, *port, *ssl)
+	m.SetMetrics(st)
+	m.SetDebug(*debug)
+
+	stop := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		select {
+		case <-signals:
+			slog.Printf("[INFO] interrupt signal")
+		case <-time.After(*duration):
+		}
+		close(stop)
+	}()
+
+	slog.Printf("[INFO] starting %d devices, %s:%d", len(tokens), *server, *port)
+	var wg sync.WaitGroup
+connect:
+	for i, token := range tokens {
+		if i > 0 && *ramp > 0 {
+			select {
+			case <-stop:
+				break connect
+			case <-time.After(*ramp):
+			}
+		}
+		wg.Add(1)
+		go func(token string) {
+			defer wg.Done()
+			simulate(m, token, cfg, st, stop)
+		}(token)
+	}
+
+	<-stop
+	st.finish()
+	wg.Wait()
+	m.Stop()
+	st.report(os.Stdout)
+}
+
+// simulate connects the device through the manager and writes pins until stop is closed
+func simulate(m *blynk.Manager, token string, cfg config, st *stats, stop <-chan struct{}) {
+	start := time.Now()
+	tracker := newAcks(st)
+	defer tracker.stop()
+	device, err := m.Add(token, func(device *blynk.Blynk) {
+		device.OnResponseFunc = tracker.response
+		for _, pin := range cfg.readPins {
+			device.AddReaderHandler(pin, func(pin uint, w io.Writer) {
+				if cfg.readLatency > 0 {
+					time.Sleep(cfg.readLatency)
+				}
+				// the configured latency is not measured
+				start := time.Now()
+				fmt.Fprintf(w, "%d", rand.Intn(1000))
+				st.read(time.Since(start))
+			})
+		}
+	})
+	st.connect(time.Since(start), err)
+	if err != nil {
+		slog.Printf("[ERROR] connect failed, %s", err.Error())
+		return
+	}
+	if cfg.rate <= 0 || len(cfg.pins) == 0 {
+		<-stop
+		return
+	}
+
+	interval := time.Duration(float64(time.Second) / cfg.rate)
+	// spread writes of the devices over the interval
+	select {
+	case <-stop:
+		return
+	case <-time.After(time.Duration(rand.Int63n(int64(interval) + 1))):
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for n := 0; ; n++ {
+		pin := cfg.pins[n%len(cfg.pins)]
+		value := strconv.FormatFloat(rand.Float64()*100, 'f', 2, 64)
+		start := time.Now()
+		if err := device.VirtualWrite(pin, value); err != nil {
+			st.ack(0, err)
+		} else {
+			st.sent()
+			// pings are not counted as writes, only some writes are followed by one
+			if cfg.ackEvery > 0 && n%cfg.ackEvery == 0 {
+				if id, err := device.Ping(); err != nil {
+					st.ack(0, err)
+				} else {
+					tracker.sent(id, start)
+				}
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func readTokens(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tokens []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		token := strings.TrimSpace(scanner.Text())
+		if token == "" || strings.HasPrefix(token, "#") || seen[token] {
+			continue
+		}
+		seen[token] = true
+		tokens = append(tokens, token)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no tokens in %s", name)
+	}
+	return tokens, nil
+}
+
+func parsePins(s string) ([]uint, error) {
+	var pins []uint
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(p)), "V")
+		if p == "" {
+			continue
+		}
+		pin, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad pin %q", p)
+		}
+		pins = append(pins, uint(pin))
+	}
+	return pins, nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	blynk "github.com/OloloevReal/go-blynk"
+)
+
+var errNoAnswer = errors.New("no answer before stop")
+
+// stats collects results of all simulated devices, it is also used as blynk.Metrics of the devices
+type stats struct {
+	lock         sync.Mutex
+	start        time.Time
+	end          time.Time
+	connected    int
+	failed       int
+	connectTimes []time.Duration
+	writeTimes   []time.Duration
+	readTimes    []time.Duration
+	writes       int
+	pingRTTs     []time.Duration
+	perSecond    map[int64]int
+	errors       map[string]int
+	reconnects   int
+	authFailures int
+	sentBytes    int64
+	recvBytes    int64
+	sentMsgs     int64
+	recvMsgs     int64
+}
+
+func newStats() *stats {
+	return &stats{
+		start:     time.Now(),
+		perSecond: make(map[int64]int),
+		errors:    make(map[string]int),
+	}
+}
+
+// finish fixes the end of the test, the time of devices stopping is not counted
+func (s *stats) finish() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.end = time.Now()
+}
+
+func (s *stats) connect(d time.Duration, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err != nil {
+		s.failed++
+		s.errors["connect: "+errorCode(err)]++
+		return
+	}
+	s.connected++
+	s.connectTimes = append(s.connectTimes, d)
+}
+
+// sent counts the write for the throughput
+func (s *stats) sent() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.writes++
+	s.perSecond[int64(time.Since(s.start)/time.Second)]++
+}
+
+// ack keeps the time until the server has processed the write or the error of the write
+func (s *stats) ack(d time.Duration, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err != nil {
+		s.errors["write: "+errorCode(err)]++
+		return
+	}
+	s.writeTimes = append(s.writeTimes, d)
+}
+
+func (s *stats) read(d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.readTimes = append(s.readTimes, d)
+}
+
+func (s *stats) MessageSent(cmd blynk.BlynkCommand, bytes int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sentMsgs++
+	s.sentBytes += int64(bytes)
+}
+
+func (s *stats) MessageReceived(cmd blynk.BlynkCommand, bytes int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.recvMsgs++
+	s.recvBytes += int64(bytes)
+}
+
+func (s *stats) Reconnect() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.reconnects++
+}
+
+func (s *stats) AuthFailure() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.authFailures++
+}
+
+func (s *stats) PingRTT(rtt time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.pingRTTs = append(s.pingRTTs, rtt)
+}
+
+func (s *stats) QueueDepth(depth int) {}
+
+// HandlerDuration is not used, the library reports writer handlers too, reader handlers of the simulator are timed by read
+func (s *stats) HandlerDuration(pin uint, d time.Duration) {}
+
+func (s *stats) report(w io.Writer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.end.IsZero() {
+		s.end = time.Now()
+	}
+	elapsed := s.end.Sub(s.start)
+
+	fmt.Fprintf(w, "elapsed:      %s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "devices:      %d connected, %d failed\n", s.connected, s.failed)
+	fmt.Fprintf(w, "reconnects:   %d, auth failures: %d\n", s.reconnects, s.authFailures)
+	fmt.Fprintf(w, "messages:     %d sent (%d bytes), %d received (%d bytes)\n", s.sentMsgs, s.sentBytes, s.recvMsgs, s.recvBytes)
+	fmt.Fprintf(w, "connect:      %s\n", durations(s.connectTimes))
+	fmt.Fprintf(w, "write ack:    %s\n", durations(s.writeTimes))
+	fmt.Fprintf(w, "read handler: %s (without -read-latency)\n", durations(s.readTimes))
+	fmt.Fprintf(w, "ping rtt:     %s\n", durations(s.pingRTTs))
+
+	rates := make([]int, 0, len(s.perSecond))
+	for _, n := range s.perSecond {
+		rates = append(rates, n)
+	}
+	sort.Ints(rates)
+	fmt.Fprintf(w, "throughput:   %.1f writes/s", float64(s.writes)/elapsed.Seconds())
+	if len(rates) > 0 {
+		fmt.Fprintf(w, ", per second p50=%d p90=%d p99=%d max=%d",
+			rates[index(len(rates), 0.5)], rates[index(len(rates), 0.9)], rates[index(len(rates), 0.99)], rates[len(rates)-1])
+	}
+	fmt.Fprintln(w)
+
+	if len(s.errors) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "errors:")
+	keys := make([]string, 0, len(s.errors))
+	for k := range s.errors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %6d %s\n", s.errors[k], k)
+	}
+}
+
+func durations(ds []time.Duration) string {
+	if len(ds) == 0 {
+		return "-"
+	}
+	sorted := make([]time.Duration, len(ds))
+	copy(sorted, ds)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := len(sorted)
+	return fmt.Sprintf("n=%d p50=%s p90=%s p99=%s max=%s", n,
+		round(sorted[index(n, 0.5)]), round(sorted[index(n, 0.9)]), round(sorted[index(n, 0.99)]), round(sorted[n-1]))
+}
+
+func index(n int, p float64) int {
+	i := int(float64(n)*p+0.5) - 1
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+// errorCode groups errors for the report, server statuses are reported by code
+func errorCode(err error) string {
+	var se *blynk.StatusError
+	if errors.As(err, &se) {
+		return fmt.Sprintf("status %d %s", se.Code, blynk.GetBlynkStatus(se.Code))
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return "timeout"
+	}
+	var oe *net.OpError
+	if errors.As(err, &oe) {
+		return oe.Op + " " + oe.Err.Error()
+	}
+	return err.Error()
+}
+
+// acks measures the time until the server has processed a write. The server answers writes only on errors,
+// so some writes are followed by a ping, its answer comes after the statuses of the earlier writes.
+// Error statuses of all writes are counted.
+type acks struct {
+	stats   *stats
+	lock    sync.Mutex
+	pending map[uint16]time.Time
+}
+
+func newAcks(st *stats) *acks {
+	return &acks{stats: st, pending: make(map[uint16]time.Time)}
+}
+
+// sent registers the ping that follows the write started at start
+func (a *acks) sent(pingID uint16, start time.Time) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.pending[pingID] = start
+}
+
+// response is the OnResponseFunc of the device
+func (a *acks) response(resp *blynk.BlynkRespose) {
+	a.lock.Lock()
+	start, ok := a.pending[resp.MessageId]
+	delete(a.pending, resp.MessageId)
+	a.lock.Unlock()
+
+	switch {
+	case ok:
+		a.stats.ack(time.Since(start), nil)
+	case resp.Status != blynk.BLYNK_SUCCESS:
+		a.stats.ack(0, &blynk.StatusError{Code: resp.Status, Command: blynk.BLYNK_CMD_HARDWARE, MessageID: resp.MessageId})
+	}
+}
+
+// stop counts writes without an answer
+func (a *acks) stop() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for range a.pending {
+		a.stats.ack(0, errNoAnswer)
+	}
+	a.pending = make(map[uint16]time.Time)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	blynk "github.com/OloloevReal/go-blynk"
+	"github.com/OloloevReal/go-blynk/server"
+)
+
+func TestAcks(t *testing.T) {
+	st := newStats()
+	a := newAcks(st)
+
+	a.sent(2, time.Now())
+	a.response(&blynk.BlynkRespose{Command: blynk.BLYNK_CMD_RESPONSE, MessageId: 2, Status: blynk.BLYNK_SUCCESS})
+
+	// error statuses of writes without a ping are counted too
+	a.sent(5, time.Now())
+	a.response(&blynk.BlynkRespose{Command: blynk.BLYNK_CMD_RESPONSE, MessageId: 3, Status: blynk.BLYNK_NO_ACTIVE_DASHBOARD})
+	a.response(&blynk.BlynkRespose{Command: blynk.BLYNK_CMD_RESPONSE, MessageId: 4, Status: blynk.BLYNK_NO_ACTIVE_DASHBOARD})
+	a.response(&blynk.BlynkRespose{Command: blynk.BLYNK_CMD_RESPONSE, MessageId: 5, Status: blynk.BLYNK_SUCCESS})
+
+	a.sent(7, time.Now())
+	a.stop()
+
+	if len(st.writeTimes) != 2 {
+		t.Fatalf("%d acknowledged writes, want 2", len(st.writeTimes))
+	}
+	if n := st.errors["write: status 8 NO_ACTIVE_DASHBOARD"]; n != 2 {
+		t.Fatalf("errors %v", st.errors)
+	}
+	if n := st.errors["write: "+errNoAnswer.Error()]; n != 1 {
+		t.Fatalf("errors %v", st.errors)
+	}
+}
+
+func TestSimulatePingsAreNotWrites(t *testing.T) {
+	store := server.NewStore()
+	store.AddDevice(server.Device{Token: "sensor"})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.NewServer(store)
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	st := newStats()
+	m := blynk.NewManager()
+	m.SetServer("127.0.0.1", ln.Addr().(*net.TCPAddr).Port, false)
+	m.SetMetrics(st)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		simulate(m, "sensor", config{rate: 100, ackEvery: 5, pins: []int{1}}, st, stop)
+		close(done)
+	}()
+	time.Sleep(300 * time.Millisecond)
+	close(stop)
+	<-done
+	m.Stop()
+
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	pings := (st.writes + 4) / 5
+	if st.writes < 5 {
+		t.Fatalf("%d writes", st.writes)
+	}
+	for e := range st.errors {
+		// the last ping can be left without an answer at stop
+		if e != "write: "+errNoAnswer.Error() {
+			t.Fatalf("errors %v", st.errors)
+		}
+	}
+	if len(st.writeTimes) > pings {
+		t.Fatalf("%d acks for %d writes, want at most %d", len(st.writeTimes), st.writes, pings)
+	}
+	// login and internal are sent before the writes
+	if sent := int(st.sentMsgs) - 2; sent != st.writes+pings {
+		t.Fatalf("%d messages sent for %d writes, want %d", sent, st.writes, st.writes+pings)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	blynk "github.com/OloloevReal/go-blynk"
+	slog "github.com/OloloevReal/go-simple-log"
+)
+
+const (
+	EXIT_OK          = 0
+	EXIT_ERROR       = 1
+	EXIT_USAGE       = 2
+	EXIT_TIMEOUT     = 3
+	EXIT_STATUS_BASE = 10
+	EXIT_STATUS_MAX  = 255
+)
+
+const usage = `Usage: blynkctl [flags] command [args]
+
+Commands:
+  write V5 12.3 [more values]   write values to the virtual pin
+  read V5 [V6 ...]              read values of the virtual pins
+  dwrite 12 on                  write 0/1, true/false or on/off to the digital pin
+  notify "message"              send push notification
+  email to subject body         send email
+  tweet "message"               send tweet
+  monitor                       print incoming vw/dw as JSON lines
+
+Exit codes: 0 success, 1 error, 2 usage, 3 timeout, 10+N server status N (255 for N >= 245)
+
+Flags:
+`
+
+type monitorLine struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Pin     string    `json:"pin"`
+	Values  []string  `json:"values"`
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	auth := flag.String("auth", os.Getenv("BLYNK_TOKEN"), "blynk token, env BLYNK_TOKEN")
+	server := flag.String("server", envOr("BLYNK_SERVER", "blynk-cloud.com"), "blynk server, env BLYNK_SERVER")
+	port := flag.Int("port", envInt("BLYNK_PORT", 443), "blynk server port, env BLYNK_PORT")
+	ssl := flag.Bool("ssl", envOr("BLYNK_SSL", "true") == "true", "use TLS, env BLYNK_SSL")
+	timeout := flag.Duration("timeout", time.Second*5, "timeout of connect, read and of the server status of commands")
+	debug := flag.Bool("debug", false, "debug logging")
+	flag.Parse()
+
+	if flag.NArg() < 1 || *auth == "" {
+		flag.Usage()
+		os.Exit(EXIT_USAGE)
+	}
+	if *debug {
+		slog.SetOptions(slog.SetDebug)
+	}
+
+	app := blynk.NewBlynk(*auth)
+	app.DisableLogo(true)
+	app.SetServer(*server, *port, *ssl)
+	app.SetResponseTimeout(*timeout)
+	if *debug {
+		app.SetDebug()
+	}
+	if err := app.Connect(); err != nil {
+		exit(err)
+	}
+
+	err := run(app, flag.Arg(0), flag.Args()[1:], *timeout)
+	app.Disconnect()
+	exit(err)
+}
+
+func run(app *blynk.Blynk, cmd string, args []string, timeout time.Duration) error {
+	switch cmd {
+	case "write":
+		if len(args) < 2 {
+			return errUsage
+		}
+		pin, err := parsePin(args[0], "V")
+		if err != nil {
+			return err
+		}
+		return withStatus(app, blynk.BLYNK_CMD_HARDWARE, timeout, func() error {
+			return app.VirtualWrite(pin, args[1:]...)
+		})
+	case "dwrite":
+		if len(args) != 2 {
+			return errUsage
+		}
+		pin, err := parsePin(args[0], "D")
+		if err != nil {
+			return err
+		}
+		value, err := parseDigital(args[1])
+		if err != nil {
+			return err
+		}
+		return withStatus(app, blynk.BLYNK_CMD_HARDWARE, timeout, func() error {
+			return app.DigitalWrite(pin, value)
+		})
+	case "read":
+		if len(args) < 1 {
+			return errUsage
+		}
+		return read(app, args, timeout)
+	case "notify":
+		if len(args) != 1 {
+			return errUsage
+		}
+		return app.Notify(args[0])
+	case "tweet":
+		if len(args) != 1 {
+			return errUsage
+		}
+		return app.Tweet(args[0])
+	case "email":
+		if len(args) != 3 {
+			return errUsage
+		}
+		return app.EMail(args[0], args[1], args[2])
+	case "monitor":
+		return monitor(app)
+	default:
+		return errUsage
+	}
+}
+
+var (
+	errUsage   = errors.New("bad command or arguments")
+	errTimeout = errors.New("timeout")
+)
+
+// watchResponses passes responses of the server, except heartbeat pings, to the channel
+func watchResponses(app *blynk.Blynk) chan *blynk.BlynkRespose {
+	responses := make(chan *blynk.BlynkRespose, 16)
+	app.OnResponseFunc = func(resp *blynk.BlynkRespose) {
+		select {
+		case responses <- resp:
+		default:
+		}
+	}
+	return responses
+}
+
+func statusError(cmd blynk.BlynkCommand, resp *blynk.BlynkRespose) error {
+	if resp.Status == blynk.BLYNK_SUCCESS {
+		return nil
+	}
+	return &blynk.StatusError{Code: resp.Status, Command: cmd, MessageID: resp.MessageId}
+}
+
+// withStatus calls send and waits for the server status. The server answers writes only on errors,
+// so a ping is sent after the command, its answer comes after the status of the command.
+func withStatus(app *blynk.Blynk, cmd blynk.BlynkCommand, timeout time.Duration, send func() error) error {
+	responses := watchResponses(app)
+	go app.Processing()
+	defer app.Stop()
+
+	if err := send(); err != nil {
+		return err
+	}
+	id, err := app.Ping()
+	if err != nil {
+		return err
+	}
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case resp := <-responses:
+			if err := statusError(cmd, resp); err != nil {
+				return err
+			}
+			if resp.MessageId == id {
+				return nil
+			}
+		case <-deadline:
+			return errTimeout
+		}
+	}
+}
+
+func read(app *blynk.Blynk, args []string, timeout time.Duration) error {
+	pins := make([]int, 0, len(args))
+	for _, a := range args {
+		pin, err := parsePin(a, "V")
+		if err != nil {
+			return err
+		}
+		pins = append(pins, pin)
+	}
+
+	var lock sync.Mutex
+	values := make(map[int][]string)
+	done := make(chan struct{})
+	app.OnReadFunc = func(resp *blynk.BlynkRespose) {
+		if len(resp.Values) < 3 || resp.Values[0] != "vw" {
+			return
+		}
+		pin, err := strconv.Atoi(resp.Values[1])
+		if err != nil {
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		if _, ok := values[pin]; ok || !contains(pins, pin) {
+			return
+		}
+		values[pin] = resp.Values[2:]
+		if len(values) == len(pins) {
+			close(done)
+		}
+	}
+	responses := watchResponses(app)
+	go app.Processing()
+	defer app.Stop()
+
+	if err := app.VirtualRead(pins...); err != nil {
+		return err
+	}
+
+	var err error
+	deadline := time.After(timeout)
+wait:
+	for {
+		select {
+		case <-done:
+			break wait
+		case resp := <-responses:
+			if err = statusError(blynk.BLYNK_CMD_HARDWARE_SYNC, resp); err != nil {
+				break wait
+			}
+		case <-deadline:
+			err = errTimeout
+			break wait
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	for _, pin := range pins {
+		if v, ok := values[pin]; ok {
+			fmt.Printf("V%d %s\n", pin, strings.Join(v, " "))
+		}
+	}
+	return err
+}
+
+func monitor(app *blynk.Blynk) error {
+	enc := json.NewEncoder(os.Stdout)
+	app.OnReadFunc = func(resp *blynk.BlynkRespose) {
+		if len(resp.Values) < 2 || (resp.Values[0] != "vw" && resp.Values[0] != "dw") {
+			return
+		}
+		prefix := "V"
+		if resp.Values[0] == "dw" {
+			prefix = "D"
+		}
+		enc.Encode(monitorLine{
+			Time:    time.Now(),
+			Command: resp.Values[0],
+			Pin:     prefix + resp.Values[1],
+			Values:  resp.Values[2:],
+		})
+	}
+
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+		app.Stop()
+	}()
+	app.Processing()
+	return nil
+}
+
+func contains(pins []int, pin int) bool {
+	for _, p := range pins {
+		if p == pin {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePin(s string, prefix string) (int, error) {
+	pin, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(s), prefix))
+	if err != nil || pin < 0 {
+		return 0, fmt.Errorf("bad pin %q", s)
+	}
+	return pin, nil
+}
+
+func parseDigital(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "true", "on":
+		return true, nil
+	case "0", "false", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("bad digital value %q, 0/1, true/false or on/off", s)
+}
+
+func exit(err error) {
+	if err == nil {
+		os.Exit(EXIT_OK)
+	}
+	fmt.Fprintln(os.Stderr, "blynkctl:", err)
+
+	var se *blynk.StatusError
+	switch {
+	case errors.As(err, &se):
+		//exit status is one byte
+		code := EXIT_STATUS_BASE + int(se.Code)
+		if code > EXIT_STATUS_MAX {
+			code = EXIT_STATUS_MAX
+		}
+		os.Exit(code)
+	case err == errUsage:
+		flag.Usage()
+		os.Exit(EXIT_USAGE)
+	case err == errTimeout:
+		os.Exit(EXIT_TIMEOUT)
+	default:
+		os.Exit(EXIT_ERROR)
+	}
+}
+
+func envOr(key string, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
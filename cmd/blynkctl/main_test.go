@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	blynk "github.com/OloloevReal/go-blynk"
+	"github.com/OloloevReal/go-blynk/server"
+)
+
+func TestParseDigital(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"1", true}, {"true", true}, {"on", true}, {"ON", true},
+		{"0", false}, {"false", false}, {"off", false}, {"False", false},
+	}
+	for _, tt := range tests {
+		if got, err := parseDigital(tt.in); err != nil || got != tt.want {
+			t.Errorf("parseDigital(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "2", "yes", "-1", "1.0"} {
+		if _, err := parseDigital(in); err == nil {
+			t.Errorf("parseDigital(%q) accepts the value", in)
+		}
+	}
+}
+
+func TestParsePin(t *testing.T) {
+	if pin, err := parsePin("v5", "V"); err != nil || pin != 5 {
+		t.Fatalf("parsePin(v5) = %d, %v", pin, err)
+	}
+	if pin, err := parsePin("12", "D"); err != nil || pin != 12 {
+		t.Fatalf("parsePin(12) = %d, %v", pin, err)
+	}
+	for _, in := range []string{"V-1", "X5", ""} {
+		if _, err := parsePin(in, "V"); err == nil {
+			t.Errorf("parsePin(%q) accepts the pin", in)
+		}
+	}
+}
+
+func TestRunAgainstServer(t *testing.T) {
+	store := server.NewStore()
+	store.AddDevice(server.Device{Token: "lamp"})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.NewServer(store)
+	go srv.Serve(ln)
+	defer srv.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	connect := func() *blynk.Blynk {
+		app := blynk.NewBlynk("lamp")
+		app.DisableLogo(true)
+		app.SetServer("127.0.0.1", port, false)
+		app.SetResponseTimeout(time.Second)
+		if err := app.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		return app
+	}
+
+	app := connect()
+	if err := run(app, "dwrite", []string{"D3", "maybe"}, time.Second); err == nil {
+		t.Fatal("dwrite accepts a bad value")
+	}
+	if err := run(app, "notify", []string{"hello"}, time.Second); !errors.Is(err, blynk.ErrIllegalCommand) {
+		t.Fatalf("notify: %v, want ErrIllegalCommand", err)
+	}
+	if err := run(app, "write", []string{"V5", "12"}, time.Second); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if values, ok := store.Pin("lamp", "V5"); !ok || len(values) != 1 || values[0] != "12" {
+		t.Fatalf("stored V5 %v, %v", values, ok)
+	}
+	if err := run(connect(), "read", []string{"V5"}, time.Second); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package blynk
+
+import (
+	"fmt"
+	"strconv"
+
+	protocol "github.com/OloloevReal/go-blynk/protocol"
+)
+
+var ErrIncompleteFrame = protocol.ErrIncompleteFrame
+
+type FrameError = protocol.FrameError
+
+// DecodeFrame decodes the first frame of buf and returns the number of consumed bytes.
+// ErrIncompleteFrame is returned when buf holds only a part of the frame.
+func DecodeFrame(buf []byte) (*BlynkRespose, int, error) {
+	f, n, err := protocol.DecodeFrame(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+	return newBlynkRespose(f), n, nil
+}
+
+// EncodeFrame is the reverse of DecodeFrame, Status is calculated from Values for all commands except response
+func EncodeFrame(resp *BlynkRespose) ([]byte, error) {
+	if resp == nil {
+		return nil, fmt.Errorf("BlynkRespose is nil")
+	}
+
+	f, err := protocol.NewFrame(resp.Command, resp.MessageId, resp.Values...)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Command == BLYNK_CMD_RESPONSE {
+		f.Length = resp.Status
+	}
+	return protocol.AppendFrame(nil, f)
+}
+
+// decodeFrames decodes all complete frames of buf and returns the number of consumed bytes
+func decodeFrames(buf []byte) ([]*BlynkRespose, int) {
+	var resps []*BlynkRespose
+	consumed := 0
+	for {
+		resp, n, err := DecodeFrame(buf[consumed:])
+		if err != nil {
+			return resps, consumed
+		}
+		resps = append(resps, resp)
+		consumed += n
+	}
+}
+
+// hardwarePin validates hardware frame in form of "vw|vr|dw|dr pin [values...]"
+func hardwarePin(resp *BlynkRespose) (int, error) {
+	if len(resp.Values) < 2 {
+		return 0, &FrameError{Command: resp.Command, MessageId: resp.MessageId, Reason: "hardware command without pin"}
+	}
+	pin, err := strconv.Atoi(resp.Values[1])
+	if err != nil || pin < 0 {
+		return 0, &FrameError{Command: resp.Command, MessageId: resp.MessageId, Reason: fmt.Sprintf("bad pin %q", resp.Values[1])}
+	}
+	if (resp.Values[0] == "vw" || resp.Values[0] == "dw") && len(resp.Values) < 3 {
+		return 0, &FrameError{Command: resp.Command, MessageId: resp.MessageId, Reason: "write command without value"}
+	}
+	return pin, nil
+}
@@ -0,0 +1,77 @@
+package blynk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func FuzzDecodeFrames(f *testing.F) {
+	f.Add([]byte("\x14\x00\x01\x00\x08vw\x001\x00255\x00\x00\x02\x00\xc8\x14\x00"))
+	f.Add([]byte{0x11, 0x00, 0x03, 0x00, 0x07, 'r', 't', 'c', 0x00, '1', '2', '3'})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		resps, consumed := decodeFrames(data)
+		if consumed > len(data) {
+			t.Fatalf("consumed %d of %d bytes", consumed, len(data))
+		}
+		if _, _, err := DecodeFrame(data[consumed:]); err != ErrIncompleteFrame {
+			t.Fatalf("tail is left with error %v", err)
+		}
+
+		var buf []byte
+		for _, resp := range resps {
+			frame, err := EncodeFrame(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf = append(buf, frame...)
+		}
+		if !bytes.Equal(buf, data[:consumed]) {
+			t.Fatalf("encoded %x, want %x", buf, data[:consumed])
+		}
+	})
+}
+
+// hardwareFrame builds the frame of the hardware command with the raw body
+func hardwareFrame(id uint16, body string) []byte {
+	return append([]byte{byte(BLYNK_CMD_HARDWARE), byte(id >> 8), byte(id), byte(len(body) >> 8), byte(len(body))}, body...)
+}
+
+func TestProcessShortHardwareFrames(t *testing.T) {
+	bodies := []string{"", "vw", "vr", "dw", "vw\x00", "vw\x00x", "vw\x00-1\x001", "vw\x005", "vr\x00x", "dw\x002"}
+
+	g, _ := newRecordBlynk()
+	for i, body := range bodies {
+		resp, _, err := DecodeFrame(hardwareFrame(uint16(i+1), body))
+		if err != nil {
+			t.Fatalf("body %q: %v", body, err)
+		}
+		var fe *FrameError
+		if err := g.handleHardware(resp); !errors.As(err, &fe) {
+			t.Errorf("body %q: error %v, want FrameError", body, err)
+		}
+	}
+
+	var writes []string
+	g.AddWriterHandler(5, func(pin uint, r io.Reader) {
+		v, _ := io.ReadAll(r)
+		writes = append(writes, string(v))
+	})
+	g.AddReaderHandler(5, func(pin uint, w io.Writer) {
+		t.Error("reader is called for a bad frame")
+	})
+
+	var buf []byte
+	for i, body := range bodies {
+		buf = append(buf, hardwareFrame(uint16(i+1), body)...)
+	}
+	// a valid frame after the bad ones is still handled
+	buf = append(buf, hardwareFrame(100, "vw\x005\x00ok")...)
+	if pending := g.process(nil, buf); len(pending) != 0 {
+		t.Fatalf("%d bytes are left", len(pending))
+	}
+	if len(writes) != 1 || writes[0] != "ok" {
+		t.Fatalf("writes %q, want only the valid one", writes)
+	}
+}
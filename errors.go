@@ -0,0 +1,53 @@
+package blynk
+
+import (
+	"fmt"
+)
+
+// StatusError is returned when the server answers with unsuccessful status code.
+// errors.Is matches StatusError by Code, so the sentinels below can be used for any command.
+type StatusError struct {
+	Code      uint16
+	Command   BlynkCommand
+	MessageID uint16
+}
+
+var (
+	ErrQuotaLimit          = &StatusError{Code: BLYNK_QUOTA_LIMIT}
+	ErrIllegalCommand      = &StatusError{Code: BLYNK_ILLEGAL_COMMAND}
+	ErrIllegalCommandBody  = &StatusError{Code: BLYNK_ILLEGAL_COMMAND_BODY}
+	ErrNotRegistered       = &StatusError{Code: BLYNK_NOT_REGISTERED}
+	ErrNotAuthenticated    = &StatusError{Code: BLYNK_NOT_AUTHENTICATED}
+	ErrNotAllowed          = &StatusError{Code: BLYNK_NOT_ALLOWED}
+	ErrNoActiveDashboard   = &StatusError{Code: BLYNK_NO_ACTIVE_DASHBOARD}
+	ErrInvalidToken        = &StatusError{Code: BLYNK_INVALID_TOKEN}
+	ErrNtfInvalidBody      = &StatusError{Code: BLYNK_NTF_INVALID_BODY}
+	ErrNtfNotAuthorized    = &StatusError{Code: BLYNK_NTF_NOT_AUTHORIZED}
+	ErrNtfException        = &StatusError{Code: BLYNK_NTF_EXCEPTION}
+	ErrDeviceWentOffline   = &StatusError{Code: BLYNK_DEVICE_WENT_OFFLINE}
+	ErrServerException     = &StatusError{Code: BLYNK_SERVER_EXCEPTION}
+	ErrNotSupportedVersion = &StatusError{Code: BLYNK_NOT_SUPPORTED_VERSION}
+	ErrEnergyLimit         = &StatusError{Code: BLYNK_ENERGY_LIMIT}
+)
+
+func newStatusError(cmd BlynkCommand, head *BlynkHead) *StatusError {
+	return &StatusError{Code: head.Length, Command: cmd, MessageID: head.MessageId}
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("blynk: %s failed, message id-%d, cause: %s (%d)", e.Command, e.MessageID, GetBlynkStatus(e.Code), e.Code)
+}
+
+func (e *StatusError) Is(target error) bool {
+	t, ok := target.(*StatusError)
+	return ok && t.Code == e.Code
+}
+
+// IsAuthError reports whether the code means the token is rejected and retry will not help
+func (e *StatusError) IsAuthError() bool {
+	switch e.Code {
+	case BLYNK_INVALID_TOKEN, BLYNK_NOT_AUTHENTICATED, BLYNK_NOT_REGISTERED, BLYNK_NOT_ALLOWED:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package blynk
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStatusErrorIs(t *testing.T) {
+	err := &StatusError{Code: BLYNK_QUOTA_LIMIT, Command: BLYNK_CMD_NOTIFY, MessageID: 3}
+	if !errors.Is(err, ErrQuotaLimit) {
+		t.Error("status error does not match the sentinel of its code")
+	}
+	if errors.Is(err, ErrInvalidToken) {
+		t.Error("status error matches the sentinel of another code")
+	}
+
+	wrapped := fmt.Errorf("notify: %w", err)
+	if !errors.Is(wrapped, ErrQuotaLimit) {
+		t.Error("wrapped status error does not match the sentinel")
+	}
+	var se *StatusError
+	if !errors.As(wrapped, &se) || se.Command != BLYNK_CMD_NOTIFY || se.MessageID != 3 {
+		t.Fatalf("errors.As() = %+v", se)
+	}
+	if got := err.Error(); got != "blynk: NOTIFY failed, message id-3, cause: QUOTA_LIMIT (1)" {
+		t.Errorf("Error() = %s", got)
+	}
+}
+
+func TestStatusErrorIsAuthError(t *testing.T) {
+	tests := []struct {
+		err  *StatusError
+		auth bool
+	}{
+		{ErrInvalidToken, true},
+		{ErrNotAuthenticated, true},
+		{ErrNotRegistered, true},
+		{ErrNotAllowed, true},
+		{ErrQuotaLimit, false},
+		{ErrServerException, false},
+		{ErrIllegalCommand, false},
+	}
+	for _, tt := range tests {
+		if got := tt.err.IsAuthError(); got != tt.auth {
+			t.Errorf("code %d: IsAuthError() = %v, want %v", tt.err.Code, got, tt.auth)
+		}
+	}
+}
+
+// responseFrame is the status of the server for the message id
+func responseFrame(id uint16, status uint16) []byte {
+	return []byte{byte(BLYNK_CMD_RESPONSE), byte(id >> 8), byte(id), byte(status >> 8), byte(status)}
+}
+
+func TestNotifyStatusWhileProcessing(t *testing.T) {
+	tests := []struct {
+		name   string
+		call   func(g *Blynk) error
+		status uint16
+		want   error
+	}{
+		{"notify quota", func(g *Blynk) error { return g.Notify("hi") }, BLYNK_QUOTA_LIMIT, ErrQuotaLimit},
+		{"notify ok", func(g *Blynk) error { return g.Notify("hi") }, BLYNK_SUCCESS, nil},
+		{"tweet", func(g *Blynk) error { return g.Tweet("hi") }, BLYNK_NTF_NOT_AUTHORIZED, ErrNtfNotAuthorized},
+		{"email", func(g *Blynk) error { return g.EMail("a@b.c", "s", "b") }, BLYNK_NTF_INVALID_BODY, ErrNtfInvalidBody},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, conn := newRecordBlynk()
+			g.processingUsing = true
+			var others []uint16
+			g.OnResponseFunc = func(resp *BlynkRespose) {
+				others = append(others, resp.MessageId)
+			}
+
+			errs := make(chan error, 1)
+			go func() { errs <- tt.call(g) }()
+			var sent []byte
+			for len(sent) < BLYNK_HEAD_SIZE {
+				time.Sleep(time.Millisecond)
+				sent = append(sent, conn.Take()...)
+			}
+			id := uint16(sent[1])<<8 | uint16(sent[2])
+			// an unrelated status goes to OnResponseFunc and does not finish the wait
+			g.process(nil, responseFrame(id+100, BLYNK_ILLEGAL_COMMAND))
+			g.process(nil, responseFrame(id, tt.status))
+
+			err := <-errs
+			if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("error %v, want %v", err, tt.want)
+			}
+			if len(others) != 1 || others[0] != id+100 {
+				t.Fatalf("OnResponseFunc got %v, want only the unrelated status", others)
+			}
+			if len(g.statusWaiters) != 0 {
+				t.Fatalf("%d waiters are left", len(g.statusWaiters))
+			}
+		})
+	}
+}
+
+func TestNotifyStatusTimeout(t *testing.T) {
+	g, _ := newRecordBlynk()
+	g.processingUsing = true
+	g.SetResponseTimeout(10 * time.Millisecond)
+	if err := g.Notify("hi"); err == nil || errors.Is(err, ErrQuotaLimit) {
+		t.Fatalf("error %v, want timeout", err)
+	}
+}
@@ -17,6 +17,7 @@ func main() {
 	flag.Parse()
 	slog.Println(*email)
 	app := blynk.NewBlynk(*auth)
+	app.SetDebug()
 
 	if err := app.Connect(); err != nil {
 		slog.Fatalln(err)
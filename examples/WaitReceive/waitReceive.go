@@ -25,6 +25,7 @@ func main() {
 	flag.Parse()
 
 	app := blynk.NewBlynk(*auth)
+	app.SetDebug()
 
 	go func() {
 		stop := make(chan os.Signal, 1)
@@ -42,9 +43,7 @@ func main() {
 		slog.Fatalln(err)
 	}
 
-	go func() {
-		time.Sleep(time.Second)
-
+	app.Timer().SetTimeout(time.Second, func() {
 		if err := app.VirtualWrite(0, "7.654"); err != nil {
 			slog.Println("[ERROR] Send command failed")
 		}
@@ -54,8 +53,9 @@ func main() {
 		if err := app.DigitalWrite(12, true); err != nil {
 			slog.Println("[ERROR] Send command failed")
 		}
+	})
 
-		time.Sleep(time.Second)
+	app.Timer().SetTimeout(time.Second*2, func() {
 		if err := app.VirtualRead(0, 1, 3, 2); err != nil {
 			slog.Println("[ERROR] Read value failed")
 		}
@@ -65,7 +65,7 @@ func main() {
 		if err := app.DigitalRead(12); err != nil {
 			slog.Println("[ERROR] Read value failed")
 		}
-	}()
+	})
 
 	app.Processing()
 }
@@ -0,0 +1,334 @@
+package blynk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	GATEWAY_EVENTS_BUFFER = 16
+	GATEWAY_KEEPALIVE     = time.Second * 15
+	GATEWAY_MAX_BODY      = 64 * 1024
+)
+
+type PinValue struct {
+	Pin    string    `json:"pin"`
+	Values []string  `json:"values"`
+	Time   time.Time `json:"time"`
+}
+
+// Gateway exposes virtual pins of the device over HTTP:
+//
+//	GET /pins        last known values of all pins
+//	GET /pins/V5     value from the reader handler, or the last known value
+//	PUT /pins/V5     calls the writer handlers and writes the value to the server
+//	GET /events      Server-Sent Events stream of pin changes
+//
+// Last values are collected from app writes and VirtualWrite calls made after NewGateway.
+// Handlers run on the request goroutine, but never concurrently with the handlers called by the processor.
+type Gateway struct {
+	blynk   *Blynk
+	lock    sync.Mutex
+	values  map[int]PinValue
+	clients map[chan PinValue]bool
+	watcher int
+}
+
+func (g *Blynk) NewGateway() *Gateway {
+	gw := &Gateway{
+		blynk:   g,
+		values:  make(map[int]PinValue),
+		clients: make(map[chan PinValue]bool),
+	}
+	gw.watcher = g.watchPins(gw.pinChanged)
+	return gw
+}
+
+// Close stops collecting of pin values and finishes event streams
+func (gw *Gateway) Close() {
+	gw.blynk.unwatchPins(gw.watcher)
+	gw.lock.Lock()
+	defer gw.lock.Unlock()
+	for ch := range gw.clients {
+		close(ch)
+	}
+	gw.clients = make(map[chan PinValue]bool)
+}
+
+func (gw *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "pins" && r.Method == http.MethodGet:
+		gw.list(w)
+	case path == "events" && r.Method == http.MethodGet:
+		gw.events(w, r)
+	case strings.HasPrefix(path, "pins/"):
+		pin, err := parseVirtualPin(strings.TrimPrefix(path, "pins/"))
+		if err != nil {
+			writeJSONError(w, http.StatusNotFound, err)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			gw.read(w, pin)
+		case http.MethodPut, http.MethodPost:
+			gw.write(w, r, pin)
+		default:
+			writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("gateway: method %s not allowed", r.Method))
+		}
+	default:
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("gateway: %s not found", r.URL.Path))
+	}
+}
+
+func (gw *Gateway) list(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, gw.snapshot())
+}
+
+// snapshot returns last values ordered by pin number
+func (gw *Gateway) snapshot() []PinValue {
+	gw.lock.Lock()
+	defer gw.lock.Unlock()
+	pins := make([]int, 0, len(gw.values))
+	for pin := range gw.values {
+		pins = append(pins, pin)
+	}
+	sort.Ints(pins)
+	values := make([]PinValue, 0, len(pins))
+	for _, pin := range pins {
+		values = append(values, gw.values[pin])
+	}
+	return values
+}
+
+func (gw *Gateway) read(w http.ResponseWriter, pin int) {
+	if value, ok := gw.blynk.readPin(pin); ok {
+		writeJSON(w, http.StatusOK, PinValue{Pin: pinName(pin), Values: []string{value}, Time: time.Now()})
+		return
+	}
+
+	gw.lock.Lock()
+	v, ok := gw.values[pin]
+	gw.lock.Unlock()
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("gateway: no value of %s", pinName(pin)))
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (gw *Gateway) write(w http.ResponseWriter, r *http.Request, pin int) {
+	values, err := parsePinBody(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	gw.blynk.writePin(pin, values)
+	if err := gw.blynk.VirtualWrite(pin, values...); err != nil {
+		writeJSONError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, PinValue{Pin: pinName(pin), Values: values, Time: time.Now()})
+}
+
+func (gw *Gateway) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("gateway: streaming is not supported"))
+		return
+	}
+
+	snapshot := gw.snapshot()
+	ch := make(chan PinValue, GATEWAY_EVENTS_BUFFER)
+	gw.lock.Lock()
+	gw.clients[ch] = true
+	gw.lock.Unlock()
+	defer gw.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, v := range snapshot {
+		writeEvent(w, v)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(GATEWAY_KEEPALIVE)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case v, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeEvent(w, v)
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+func (gw *Gateway) unsubscribe(ch chan PinValue) {
+	gw.lock.Lock()
+	defer gw.lock.Unlock()
+	if gw.clients[ch] {
+		delete(gw.clients, ch)
+		close(ch)
+	}
+}
+
+// pinChanged keeps the value and sends it to the event streams, slow streams lose events
+func (gw *Gateway) pinChanged(pin int, values []string) {
+	v := PinValue{Pin: pinName(pin), Values: append([]string(nil), values...), Time: time.Now()}
+	gw.lock.Lock()
+	defer gw.lock.Unlock()
+	gw.values[pin] = v
+	for ch := range gw.clients {
+		select {
+		case ch <- v:
+		default:
+			gw.blynk.log.Printf("[DEBUG] gateway: event stream is full, %s dropped", v.Pin)
+		}
+	}
+}
+
+func (g *Blynk) watchPins(fn func(pin int, values []string)) int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.lastWatcher++
+	g.pinWatchers[g.lastWatcher] = fn
+	return g.lastWatcher
+}
+
+func (g *Blynk) unwatchPins(id int) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	delete(g.pinWatchers, id)
+}
+
+// notifyPin reports writes of the virtual pin by the app or by VirtualWrite
+func (g *Blynk) notifyPin(pin int, values []string) {
+	g.lock.Lock()
+	watchers := make([]func(int, []string), 0, len(g.pinWatchers))
+	for _, fn := range g.pinWatchers {
+		watchers = append(watchers, fn)
+	}
+	g.lock.Unlock()
+	for _, fn := range watchers {
+		fn(pin, values)
+	}
+}
+
+// parsePinBody accepts {"value": "1"}, {"values": ["1", "2"]}, ["1", "2"] or plain text
+func parsePinBody(r *http.Request) ([]string, error) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, GATEWAY_MAX_BODY))
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("gateway: empty value")
+	}
+
+	switch data[0] {
+	case '{':
+		var body struct {
+			Value  *json.RawMessage  `json:"value"`
+			Values []json.RawMessage `json:"values"`
+		}
+		if err := json.Unmarshal(data, &body); err != nil {
+			return nil, fmt.Errorf("gateway: bad body, %s", err.Error())
+		}
+		if body.Value != nil {
+			return jsonValues([]json.RawMessage{*body.Value})
+		}
+		if len(body.Values) == 0 {
+			return nil, fmt.Errorf("gateway: value or values is required")
+		}
+		return jsonValues(body.Values)
+	case '[':
+		var raw []json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("gateway: bad body, %s", err.Error())
+		}
+		if len(raw) == 0 {
+			return nil, fmt.Errorf("gateway: empty value")
+		}
+		return jsonValues(raw)
+	default:
+		return []string{string(data)}, nil
+	}
+}
+
+// jsonValues converts JSON strings, numbers and booleans to pin values
+func jsonValues(raw []json.RawMessage) ([]string, error) {
+	values := make([]string, 0, len(raw))
+	for _, r := range raw {
+		var s string
+		if err := json.Unmarshal(r, &s); err == nil {
+			values = append(values, s)
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(r, &v); err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case float64:
+			values = append(values, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			if v {
+				values = append(values, "1")
+			} else {
+				values = append(values, "0")
+			}
+		default:
+			return nil, fmt.Errorf("gateway: unsupported value %s", string(r))
+		}
+	}
+	return values, nil
+}
+
+func parseVirtualPin(s string) (int, error) {
+	if !strings.HasPrefix(strings.ToUpper(s), "V") {
+		return 0, fmt.Errorf("gateway: bad virtual pin %q", s)
+	}
+	pin, err := strconv.Atoi(s[1:])
+	if err != nil || pin < 0 {
+		return 0, fmt.Errorf("gateway: bad virtual pin %q", s)
+	}
+	return pin, nil
+}
+
+func pinName(pin int) string {
+	return "V" + strconv.Itoa(pin)
+}
+
+func writeEvent(w io.Writer, v PinValue) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "event: pin\ndata: %s\n\n", data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
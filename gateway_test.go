@@ -0,0 +1,77 @@
+package blynk
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGatewayWriteSerialisedWithProcessor(t *testing.T) {
+	g, _ := newRecordBlynk()
+	var inside, overlaps, calls int32
+	g.AddWriterHandler(5, func(pin uint, r io.Reader) {
+		if atomic.AddInt32(&inside, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&calls, 1)
+		atomic.AddInt32(&inside, -1)
+	})
+	gw := g.NewGateway()
+	defer gw.Close()
+	srv := httptest.NewServer(gw)
+	defer srv.Close()
+
+	const N = 20
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < N; i++ {
+			g.handleHardware(&BlynkRespose{Command: BLYNK_CMD_HARDWARE, Values: []string{"vw", "5", "1"}})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < N; i++ {
+			req, _ := http.NewRequest(http.MethodPut, srv.URL+"/pins/V5", strings.NewReader("2"))
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status %d", resp.StatusCode)
+			}
+		}
+	}()
+	wg.Wait()
+
+	if calls != 2*N {
+		t.Fatalf("writer called %d times, want %d", calls, 2*N)
+	}
+	if overlaps != 0 {
+		t.Fatalf("writer called concurrently %d times", overlaps)
+	}
+}
+
+func TestGatewayReadUsesReader(t *testing.T) {
+	g, _ := newRecordBlynk()
+	g.AddReaderHandler(3, func(pin uint, w io.Writer) {
+		io.WriteString(w, "21.5")
+	})
+	gw := g.NewGateway()
+	defer gw.Close()
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/pins/V3", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"values":["21.5"]`) {
+		t.Fatalf("read %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,192 @@
+package hass
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	blynk "github.com/OloloevReal/go-blynk"
+	"github.com/OloloevReal/go-blynk/mqtt"
+)
+
+// Config of discovery, topic templates use {device} and {pin} like the blynk-mqtt bridge
+type Config struct {
+	Prefix            string
+	DeviceID          string
+	DeviceName        string
+	StateTopic        string
+	CommandTopic      string
+	AvailabilityTopic string
+	QoS               byte
+}
+
+func DefaultConfig(deviceID string) Config {
+	return Config{
+		Prefix:       "homeassistant",
+		DeviceID:     deviceID,
+		DeviceName:   deviceID,
+		StateTopic:   "blynk/{device}/V{pin}",
+		CommandTopic: "blynk/{device}/V{pin}/set",
+	}
+}
+
+type device struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	SWVersion    string   `json:"sw_version"`
+}
+
+// entity is the discovery payload, fields not used by the component are omitted
+type entity struct {
+	Name              string   `json:"name"`
+	UniqueID          string   `json:"unique_id"`
+	ObjectID          string   `json:"object_id"`
+	StateTopic        string   `json:"state_topic"`
+	CommandTopic      string   `json:"command_topic,omitempty"`
+	AvailabilityTopic string   `json:"availability_topic,omitempty"`
+	Unit              string   `json:"unit_of_measurement,omitempty"`
+	DeviceClass       string   `json:"device_class,omitempty"`
+	Icon              string   `json:"icon,omitempty"`
+	PayloadOn         string   `json:"payload_on,omitempty"`
+	PayloadOff        string   `json:"payload_off,omitempty"`
+	StateOn           string   `json:"state_on,omitempty"`
+	StateOff          string   `json:"state_off,omitempty"`
+	Min               *float64 `json:"min,omitempty"`
+	Max               *float64 `json:"max,omitempty"`
+	Step              *float64 `json:"step,omitempty"`
+	QoS               byte     `json:"qos,omitempty"`
+	Device            device   `json:"device"`
+}
+
+// Messages returns retained discovery configs of all pins with metadata, ordered by pin
+func Messages(cfg Config, pins map[uint]blynk.PinMeta) ([]*mqtt.Message, error) {
+	if cfg.DeviceID == "" {
+		return nil, fmt.Errorf("hass: device id is required")
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "homeassistant"
+	}
+
+	keys := make([]int, 0, len(pins))
+	for pin := range pins {
+		keys = append(keys, int(pin))
+	}
+	sort.Ints(keys)
+
+	msgs := make([]*mqtt.Message, 0, len(keys))
+	for _, pin := range keys {
+		m, err := message(cfg, uint(pin), pins[uint(pin)])
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+// Remove returns empty retained configs which delete the entities of the pins from Home Assistant
+func Remove(cfg Config, pins map[uint]blynk.PinMeta) ([]*mqtt.Message, error) {
+	msgs, err := Messages(cfg, pins)
+	for _, m := range msgs {
+		m.Payload = nil
+	}
+	return msgs, err
+}
+
+// Publish sends discovery configs of the device pins
+func Publish(client *mqtt.Client, cfg Config, g *blynk.Blynk) error {
+	msgs, err := Messages(cfg, g.PinsMeta())
+	if err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		if err := client.Publish(m.Topic, m.Payload, m.QoS, m.Retain); err != nil {
+			return fmt.Errorf("hass: publish %s failed, %s", m.Topic, err.Error())
+		}
+	}
+	return nil
+}
+
+func message(cfg Config, pin uint, meta blynk.PinMeta) (*mqtt.Message, error) {
+	component := meta.Widget
+	if component == "" {
+		component = blynk.PIN_WIDGET_SENSOR
+	}
+	objectID := fmt.Sprintf("%s_v%d", sanitize(cfg.DeviceID), pin)
+	name := meta.Name
+	if name == "" {
+		name = fmt.Sprintf("V%d", pin)
+	}
+
+	e := entity{
+		Name:              name,
+		UniqueID:          "blynk_" + objectID,
+		ObjectID:          objectID,
+		StateTopic:        expandTopic(cfg.StateTopic, cfg.DeviceID, pin),
+		AvailabilityTopic: expandTopic(cfg.AvailabilityTopic, cfg.DeviceID, pin),
+		Unit:              meta.Unit,
+		DeviceClass:       meta.DeviceClass,
+		Icon:              meta.Icon,
+		QoS:               cfg.QoS,
+		Device: device{
+			Identifiers:  []string{"blynk_" + sanitize(cfg.DeviceID)},
+			Name:         cfg.DeviceName,
+			Manufacturer: "Blynk",
+			Model:        "go-blynk",
+			SWVersion:    blynk.Version,
+		},
+	}
+
+	switch component {
+	case blynk.PIN_WIDGET_SENSOR:
+	case blynk.PIN_WIDGET_BINARY_SENSOR:
+		e.PayloadOn, e.PayloadOff = "1", "0"
+	case blynk.PIN_WIDGET_SWITCH:
+		e.CommandTopic = expandTopic(cfg.CommandTopic, cfg.DeviceID, pin)
+		e.PayloadOn, e.PayloadOff = "1", "0"
+		e.StateOn, e.StateOff = "1", "0"
+	case blynk.PIN_WIDGET_NUMBER:
+		e.CommandTopic = expandTopic(cfg.CommandTopic, cfg.DeviceID, pin)
+		if meta.Max > meta.Min {
+			e.Min, e.Max = &meta.Min, &meta.Max
+			if meta.Step > 0 {
+				e.Step = &meta.Step
+			}
+		}
+	default:
+		return nil, fmt.Errorf("hass: unsupported widget %q of pin V%d", component, pin)
+	}
+	if e.CommandTopic == "" && component != blynk.PIN_WIDGET_SENSOR && component != blynk.PIN_WIDGET_BINARY_SENSOR {
+		return nil, fmt.Errorf("hass: command topic is required for %s of pin V%d", component, pin)
+	}
+
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return &mqtt.Message{
+		Topic:   fmt.Sprintf("%s/%s/%s/v%d/config", cfg.Prefix, component, sanitize(cfg.DeviceID), pin),
+		Payload: payload,
+		QoS:     cfg.QoS,
+		Retain:  true,
+	}, nil
+}
+
+func expandTopic(template string, device string, pin uint) string {
+	return strings.NewReplacer("{device}", device, "{pin}", strconv.FormatUint(uint64(pin), 10)).Replace(template)
+}
+
+// sanitize keeps characters allowed in discovery node and object ids
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,108 @@
+package hass
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	blynk "github.com/OloloevReal/go-blynk"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// golden compares the indented payload with testdata/name.json, the library version is replaced to keep files stable
+func golden(t *testing.T, name string, payload []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, payload, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteByte('\n')
+	got := bytes.ReplaceAll(buf.Bytes(), []byte(`"sw_version": "`+blynk.Version+`"`), []byte(`"sw_version": "VERSION"`))
+
+	path := filepath.Join("testdata", name+".json")
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s differs from golden file:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestMessagesGolden(t *testing.T) {
+	cfg := DefaultConfig("kitchen node")
+	cfg.DeviceName = "Kitchen"
+	cfg.AvailabilityTopic = "blynk/{device}/status"
+	cfg.QoS = 1
+
+	tests := []struct {
+		name  string
+		pin   uint
+		meta  blynk.PinMeta
+		topic string
+	}{
+		{"sensor", 1, blynk.PinMeta{Name: "Temperature", Unit: "°C", DeviceClass: "temperature"}, "homeassistant/sensor/kitchen_node/v1/config"},
+		{"sensor_default", 2, blynk.PinMeta{}, "homeassistant/sensor/kitchen_node/v2/config"},
+		{"binary_sensor", 3, blynk.PinMeta{Name: "Door", Widget: blynk.PIN_WIDGET_BINARY_SENSOR, DeviceClass: "door"}, "homeassistant/binary_sensor/kitchen_node/v3/config"},
+		{"switch", 4, blynk.PinMeta{Name: "Lamp", Widget: blynk.PIN_WIDGET_SWITCH, Icon: "mdi:lightbulb"}, "homeassistant/switch/kitchen_node/v4/config"},
+		{"number", 5, blynk.PinMeta{Name: "Level", Widget: blynk.PIN_WIDGET_NUMBER, Min: 0, Max: 100, Step: 5}, "homeassistant/number/kitchen_node/v5/config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs, err := Messages(cfg, map[uint]blynk.PinMeta{tt.pin: tt.meta})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(msgs) != 1 {
+				t.Fatalf("%d messages, want 1", len(msgs))
+			}
+			m := msgs[0]
+			if m.Topic != tt.topic || !m.Retain || m.QoS != 1 {
+				t.Fatalf("message %s retain %v qos %d", m.Topic, m.Retain, m.QoS)
+			}
+			golden(t, tt.name, m.Payload)
+		})
+	}
+}
+
+func TestMessagesOrderAndRemove(t *testing.T) {
+	pins := map[uint]blynk.PinMeta{
+		10: {Widget: blynk.PIN_WIDGET_SWITCH},
+		2:  {},
+	}
+	msgs, err := Remove(DefaultConfig("dev"), pins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Topic != "homeassistant/sensor/dev/v2/config" || msgs[1].Topic != "homeassistant/switch/dev/v10/config" {
+		t.Fatalf("messages %v", msgs)
+	}
+	for _, m := range msgs {
+		if m.Payload != nil || !m.Retain {
+			t.Fatalf("remove of %s has payload %q retain %v", m.Topic, m.Payload, m.Retain)
+		}
+	}
+}
+
+func TestMessagesErrors(t *testing.T) {
+	if _, err := Messages(Config{}, nil); err == nil {
+		t.Fatal("empty device id is accepted")
+	}
+	if _, err := Messages(DefaultConfig("dev"), map[uint]blynk.PinMeta{1: {Widget: "light"}}); err == nil {
+		t.Fatal("unsupported widget is accepted")
+	}
+	cfg := DefaultConfig("dev")
+	cfg.CommandTopic = ""
+	if _, err := Messages(cfg, map[uint]blynk.PinMeta{1: {Widget: blynk.PIN_WIDGET_SWITCH}}); err == nil {
+		t.Fatal("switch without command topic is accepted")
+	}
+}
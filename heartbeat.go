@@ -0,0 +1,176 @@
+package blynk
+
+import (
+	"time"
+)
+
+const (
+	BLYNK_RECONNECT_MIN = time.Second
+	BLYNK_RECONNECT_MAX = time.Minute
+)
+
+// SetHeartbeat sets ping interval announced to the server and the number of missed pings
+// after which the connection is declared dead, it should be called before Connect
+func (g *Blynk) SetHeartbeat(interval time.Duration, maxMissed int) {
+	if maxMissed < 1 {
+		maxMissed = 1
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.heartbeat = interval
+	g.maxMissedPings = maxMissed
+}
+
+// PingRTT returns round trip time of the last ping and the smoothed average
+func (g *Blynk) PingRTT() (time.Duration, time.Duration) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.lastRTT, g.avgRTT
+}
+
+func (g *Blynk) keepAlive() {
+	g.log.Printf("Keep-Alive: started")
+	defer g.log.Printf("Keep-Alive: finished")
+	t := time.NewTicker(g.heartbeat)
+	for {
+		select {
+		case <-t.C:
+			g.heartbeatTick()
+		case <-g.cancel:
+			g.log.Printf("[DEBUG] Keep-Alive: Stop received")
+			t.Stop()
+			return
+		}
+	}
+}
+
+// heartbeatAsync runs heartbeatTick off the shared timer goroutine of the Manager,
+// so a blocked write of one device doesn't stall timers of others. A tick is skipped while the previous one is running
+func (g *Blynk) heartbeatAsync() {
+	g.lock.Lock()
+	if g.heartbeatBusy {
+		g.lock.Unlock()
+		g.log.Printf("[DEBUG] Keep-Alive: previous heartbeat is still running")
+		return
+	}
+	g.heartbeatBusy = true
+	g.lock.Unlock()
+
+	go func() {
+		defer func() {
+			g.lock.Lock()
+			g.heartbeatBusy = false
+			g.lock.Unlock()
+		}()
+		g.heartbeatTick()
+	}()
+}
+
+func (g *Blynk) heartbeatTick() {
+	//a ping sent during reconnect would be taken as the answer to the login
+	if !g.IsConnected() {
+		return
+	}
+	if g.pingMissed() {
+		g.log.Printf("[ERROR] Keep-Alive: no ping response for %d heartbeats, connection is dead", g.maxMissedPings)
+		//receiver gets error and Processing starts reconnect
+		g.closeConn()
+		return
+	}
+	g.log.Printf("[DEBUG] Keep-Alive: send")
+	if id, err := g.sendCommand(BLYNK_CMD_PING); err == nil {
+		g.pingSent(id)
+	}
+}
+
+// pingMissed counts heartbeats with unanswered pings and reports whether the limit is reached
+func (g *Blynk) pingMissed() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if len(g.pings) == 0 {
+		g.missedPings = 0
+		return false
+	}
+	g.missedPings++
+	if g.missedPings < g.maxMissedPings {
+		return false
+	}
+	g.missedPings = 0
+	g.pings = make(map[uint16]time.Time)
+	return true
+}
+
+func (g *Blynk) pingSent(id uint16) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.pings[id] = g.clock.Now()
+}
+
+// pingReceived reports whether the response answers a heartbeat ping
+func (g *Blynk) pingReceived(id uint16) bool {
+	g.lock.Lock()
+	sent, ok := g.pings[id]
+	if !ok {
+		g.lock.Unlock()
+		return false
+	}
+	//server answers in order, older pings are lost
+	for pid, t := range g.pings {
+		if !t.After(sent) {
+			delete(g.pings, pid)
+		}
+	}
+	rtt := g.clock.Now().Sub(sent)
+	g.lastRTT = rtt
+	if g.avgRTT == 0 {
+		g.avgRTT = rtt
+	} else {
+		g.avgRTT += (rtt - g.avgRTT) / 8
+	}
+	g.missedPings = 0
+	g.lock.Unlock()
+
+	g.metrics.PingRTT(rtt)
+	return true
+}
+
+// Ping sends a ping outside of the heartbeat, the answer is passed to OnResponseFunc while Processing is running.
+// The server answers in order, so the answer means that all commands sent before are processed
+func (g *Blynk) Ping() (uint16, error) {
+	return g.sendCommand(BLYNK_CMD_PING)
+}
+
+func (g *Blynk) closeConn() {
+	g.lock.Lock()
+	conn := g.conn
+	g.lock.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// reconnect tries to connect with exponential backoff until success or Stop
+func (g *Blynk) reconnect() bool {
+	delay := BLYNK_RECONNECT_MIN
+	for {
+		select {
+		case <-g.cancel:
+			return false
+		case <-time.After(delay):
+		}
+
+		err := g.Connect()
+		if err == nil {
+			return true
+		}
+		g.log.Printf("[ERROR] Reconnect: failed, %s", err.Error())
+		if se, ok := err.(*StatusError); ok && se.IsAuthError() {
+			return false
+		}
+
+		delay *= 2
+		if delay > BLYNK_RECONNECT_MAX {
+			delay = BLYNK_RECONNECT_MAX
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package blynk
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatWaitsForLogin(t *testing.T) {
+	g, conn := newRecordBlynk()
+
+	g.heartbeatTick()
+	if sent := conn.Take(); len(sent) != 0 {
+		t.Fatalf("ping % x is sent before login", sent)
+	}
+
+	g.setConnected(true)
+	g.heartbeatTick()
+	sent := conn.Take()
+	if len(sent) != BLYNK_HEAD_SIZE || BlynkCommand(sent[0]) != BLYNK_CMD_PING {
+		t.Fatalf("sent % x, want ping", sent)
+	}
+}
+
+func TestSendWithoutConnection(t *testing.T) {
+	g := NewBlynk("token")
+	if err := g.sendBytes([]byte{0x06, 0x00, 0x01, 0x00, 0x00}); err == nil {
+		t.Fatal("send without connection succeeded")
+	}
+}
+
+func TestProcessorDropsTailOnReconnect(t *testing.T) {
+	g, _ := newRecordBlynk()
+	got := make(chan []string, 1)
+	g.setValuesHandler(1, func(pin uint, values []string) { got <- values })
+
+	go g.processor()
+	defer close(g.cancel)
+
+	// the old connection dies in the middle of a frame
+	g.recvMsg <- []byte{0x14, 0x00, 0x01, 0x00, 0x08, 'v', 'w'}
+	g.recvMsg <- nil
+	g.recvMsg <- []byte("\x14\x00\x02\x00\x06vw\x001\x007")
+
+	select {
+	case values := <-got:
+		if len(values) != 1 || values[0] != "7" {
+			t.Fatalf("values %q, want [7]", values)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("frame of the new connection is not handled")
+	}
+}
+
+func TestResponseHookSkipsHeartbeat(t *testing.T) {
+	g, _ := newRecordBlynk()
+	var got []*BlynkRespose
+	g.OnResponseFunc = func(resp *BlynkRespose) { got = append(got, resp) }
+
+	g.pingSent(5)
+	g.process(nil, []byte{0x00, 0x00, 0x05, 0x00, 0xC8, 0x00, 0x00, 0x06, 0x00, 0x08})
+	if len(got) != 1 || got[0].MessageId != 6 || got[0].Status != BLYNK_NO_ACTIVE_DASHBOARD {
+		t.Fatalf("hook got %+v, want response 6 with status 8", got)
+	}
+	if len(g.pings) != 0 {
+		t.Fatal("heartbeat answer is not counted")
+	}
+}
+
+// sentID returns the message id of the first frame written to conn
+func sentID(t *testing.T, conn *recordConn) uint16 {
+	t.Helper()
+	sent := conn.Take()
+	if len(sent) < BLYNK_HEAD_SIZE {
+		t.Fatalf("nothing is sent")
+	}
+	return uint16(sent[1])<<8 | uint16(sent[2])
+}
+
+func TestPingRTT(t *testing.T) {
+	g, conn := newRecordBlynk()
+	clock := newFakeClock()
+	g.SetClock(clock)
+	g.setConnected(true)
+
+	g.heartbeatTick()
+	clock.Advance(40 * time.Millisecond)
+	g.process(nil, responseFrame(sentID(t, conn), BLYNK_SUCCESS))
+	if last, avg := g.PingRTT(); last != 40*time.Millisecond || avg != 40*time.Millisecond {
+		t.Fatalf("rtt %v, avg %v, want 40ms", last, avg)
+	}
+
+	g.heartbeatTick()
+	clock.Advance(120 * time.Millisecond)
+	g.process(nil, responseFrame(sentID(t, conn), BLYNK_SUCCESS))
+	// the average moves by 1/8 of the difference
+	if last, avg := g.PingRTT(); last != 120*time.Millisecond || avg != 50*time.Millisecond {
+		t.Fatalf("rtt %v, avg %v, want 120ms and 50ms", last, avg)
+	}
+
+	// the answer to the later ping clears the earlier lost one and the missed counter
+	g.heartbeatTick()
+	conn.Take()
+	clock.Advance(time.Second)
+	g.heartbeatTick()
+	id := sentID(t, conn)
+	clock.Advance(10 * time.Millisecond)
+	g.process(nil, responseFrame(id, BLYNK_SUCCESS))
+	if last, _ := g.PingRTT(); last != 10*time.Millisecond {
+		t.Fatalf("rtt %v, want 10ms", last)
+	}
+	if len(g.pings) != 0 || g.missedPings != 0 {
+		t.Fatalf("%d pings are left, %d missed", len(g.pings), g.missedPings)
+	}
+}
+
+func TestMissedHeartbeatsCloseConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	g := NewBlynk("token")
+	g.conn = client
+	g.SetClock(newFakeClock())
+	g.SetHeartbeat(time.Second, 3)
+	g.setConnected(true)
+
+	pings := make(chan int, 1)
+	go func() {
+		n := 0
+		head := make([]byte, BLYNK_HEAD_SIZE)
+		for {
+			if _, err := io.ReadFull(server, head); err != nil {
+				pings <- n
+				return
+			}
+			if BlynkCommand(head[0]) == BLYNK_CMD_PING {
+				n++
+			}
+		}
+	}()
+
+	// pings of the first three heartbeats are not answered, the fourth one closes the connection
+	for i := 0; i < 4; i++ {
+		g.heartbeatTick()
+	}
+	select {
+	case n := <-pings:
+		if n != 3 {
+			t.Fatalf("%d pings are sent before close, want 3", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("connection is not closed")
+	}
+}
+
+// fakeHardwareServer accepts logins, pings are answered on every connection but the first one.
+// The number of the connection is sent to the channel on login
+func fakeHardwareServer(t *testing.T) (int, chan int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	logins := make(chan int, 4)
+	go func() {
+		for n := 0; ; n++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(n int, conn net.Conn) {
+				defer conn.Close()
+				var buf []byte
+				chunk := make([]byte, 1024)
+				for {
+					cnt, err := conn.Read(chunk)
+					if err != nil {
+						return
+					}
+					buf = append(buf, chunk[:cnt]...)
+					resps, consumed := decodeFrames(buf)
+					buf = buf[consumed:]
+					for _, resp := range resps {
+						switch {
+						case resp.Command == BLYNK_CMD_HW_LOGIN:
+							conn.Write(responseFrame(resp.MessageId, BLYNK_SUCCESS))
+							logins <- n
+						case resp.Command == BLYNK_CMD_INTERNAL:
+							conn.Write(responseFrame(resp.MessageId, BLYNK_SUCCESS))
+						case resp.Command == BLYNK_CMD_PING && n > 0:
+							conn.Write(responseFrame(resp.MessageId, BLYNK_SUCCESS))
+						}
+					}
+				}
+			}(n, conn)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, logins
+}
+
+func TestMissedHeartbeatsReconnect(t *testing.T) {
+	port, logins := fakeHardwareServer(t)
+	g := NewBlynk("token")
+	g.DisableLogo(true)
+	g.SetServer("127.0.0.1", port, false)
+	g.SetHeartbeat(20*time.Millisecond, 2)
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	<-logins
+	go g.Processing()
+	defer g.Stop()
+
+	select {
+	case n := <-logins:
+		if n != 1 {
+			t.Fatalf("login on connection %d, want 1", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reconnect after missed heartbeats")
+	}
+	for i := 0; !g.IsConnected(); i++ {
+		if i == 100 {
+			t.Fatal("device is not connected after reconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
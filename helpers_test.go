@@ -0,0 +1,127 @@
+package blynk
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"time"
+)
+
+// recordConn keeps everything written by the client, reads block until Close
+type recordConn struct {
+	lock   sync.Mutex
+	buf    bytes.Buffer
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newRecordConn() *recordConn {
+	return &recordConn{closed: make(chan struct{})}
+}
+
+func (c *recordConn) Read(b []byte) (int, error) {
+	<-c.closed
+	return 0, net.ErrClosed
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.buf.Write(b)
+}
+
+func (c *recordConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+// Take returns written bytes and clears the buffer
+func (c *recordConn) Take() []byte {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	b := append([]byte(nil), c.buf.Bytes()...)
+	c.buf.Reset()
+	return b
+}
+
+func (c *recordConn) LocalAddr() net.Addr                { return &net.TCPAddr{} }
+func (c *recordConn) RemoteAddr() net.Addr               { return &net.TCPAddr{} }
+func (c *recordConn) SetDeadline(t time.Time) error      { return nil }
+func (c *recordConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *recordConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func newRecordBlynk() (*Blynk, *recordConn) {
+	conn := newRecordConn()
+	g := NewBlynk("token")
+	g.conn = conn
+	return g, conn
+}
+
+// fakeClock moves only by Advance, After channels fire when the time is reached
+type fakeClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	waits   []time.Duration
+	pending []fakeWait
+}
+
+type fakeWait struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.pending = append(c.pending, fakeWait{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.pending[:0]
+	for _, w := range c.pending {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.pending = pending
+}
+
+// Waits returns durations passed to After
+func (c *fakeClock) Waits() []time.Duration {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
+
+// frameBodies splits written frames and returns their bodies
+func frameBodies(b []byte) []string {
+	var bodies []string
+	for len(b) >= BLYNK_HEAD_SIZE {
+		n := int(b[3])<<8 | int(b[4])
+		bodies = append(bodies, string(b[BLYNK_HEAD_SIZE:BLYNK_HEAD_SIZE+n]))
+		b = b[BLYNK_HEAD_SIZE+n:]
+	}
+	return bodies
+}
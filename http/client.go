@@ -0,0 +1,162 @@
+package http
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	blynk "github.com/OloloevReal/go-blynk"
+)
+
+const HTTP_TIMEOUT = time.Second * 10
+
+// Client calls HTTP API of the Blynk server, it does not keep a hardware session
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *nethttp.Client
+}
+
+func NewClient(server string, port int, ssl bool, token string) *Client {
+	scheme := "http"
+	if ssl {
+		scheme = "https"
+	}
+	return &Client{
+		BaseURL: fmt.Sprintf("%s://%s:%d", scheme, server, port),
+		Token:   token,
+		HTTP:    &nethttp.Client{Timeout: HTTP_TIMEOUT},
+	}
+}
+
+// NewClientTLS uses TLS config for https, e.g. with the certificate of a private server
+func NewClientTLS(server string, port int, token string, conf *tls.Config) *Client {
+	c := NewClient(server, port, true, token)
+	c.HTTP.Transport = &nethttp.Transport{TLSClientConfig: conf}
+	return c
+}
+
+// FromBlynk returns client with token, server and TLS config of the device
+func FromBlynk(g *blynk.Blynk) (*Client, error) {
+	server, port, ssl := g.Server()
+	if !ssl {
+		return NewClient(server, port, false, g.APIkey), nil
+	}
+	conf, err := g.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return NewClientTLS(server, port, g.APIkey, conf), nil
+}
+
+// Get returns values of the pin, pin is "V5", "D3" or "A0"
+func (c *Client) Get(pin string) (Value, error) {
+	var values []string
+	if err := c.do(nethttp.MethodGet, blynk.BLYNK_CMD_HARDWARE_SYNC, "get/"+url.PathEscape(pin), nil, nil, &values); err != nil {
+		return nil, err
+	}
+	return Value(values), nil
+}
+
+func (c *Client) VirtualRead(pin int) (Value, error) {
+	return c.Get("V" + strconv.Itoa(pin))
+}
+
+// Update writes values to the pin, the device receives them as an app write
+func (c *Client) Update(pin string, values ...string) error {
+	query := url.Values{"value": values}
+	return c.do(nethttp.MethodGet, blynk.BLYNK_CMD_HARDWARE, "update/"+url.PathEscape(pin), query, nil, nil)
+}
+
+func (c *Client) VirtualWrite(pin int, values ...string) error {
+	return c.Update("V"+strconv.Itoa(pin), values...)
+}
+
+func (c *Client) IsHardwareConnected() (bool, error) {
+	var connected bool
+	err := c.do(nethttp.MethodGet, blynk.BLYNK_CMD_RESPONSE, "isHardwareConnected", nil, nil, &connected)
+	return connected, err
+}
+
+func (c *Client) IsAppConnected() (bool, error) {
+	var connected bool
+	err := c.do(nethttp.MethodGet, blynk.BLYNK_CMD_RESPONSE, "isAppConnected", nil, nil, &connected)
+	return connected, err
+}
+
+func (c *Client) Project() (*Project, error) {
+	var raw json.RawMessage
+	if err := c.do(nethttp.MethodGet, blynk.BLYNK_CMD_RESPONSE, "project", nil, nil, &raw); err != nil {
+		return nil, err
+	}
+	p := &Project{Raw: raw}
+	if err := json.Unmarshal(raw, p); err != nil {
+		return nil, fmt.Errorf("blynk http: bad project, %s", err.Error())
+	}
+	return p, nil
+}
+
+func (c *Client) Notify(msg string) error {
+	return c.do(nethttp.MethodPost, blynk.BLYNK_CMD_NOTIFY, "notify", nil, map[string]string{"body": msg}, nil)
+}
+
+func (c *Client) EMail(to string, subject string, msg string) error {
+	body := map[string]string{"to": to, "title": subject, "subj": subject, "body": msg}
+	return c.do(nethttp.MethodPost, blynk.BLYNK_CMD_EMAIL, "email", nil, body, nil)
+}
+
+// do calls /{token}/{path}, body is sent as JSON and the response is decoded into out if it is not nil
+func (c *Client) do(method string, cmd blynk.BlynkCommand, path string, query url.Values, body interface{}, out interface{}) error {
+	u := strings.TrimRight(c.BaseURL, "/") + "/" + url.PathEscape(c.Token) + "/" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := nethttp.NewRequest(method, u, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = nethttp.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != nethttp.StatusOK {
+		return newAPIError(cmd, resp.StatusCode, string(data))
+	}
+	if out == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("blynk http: bad response %q, %s", data, err.Error())
+	}
+	return nil
+}
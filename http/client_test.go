@@ -0,0 +1,125 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	blynk "github.com/OloloevReal/go-blynk"
+)
+
+func newTestClient(t *testing.T, handler nethttp.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &Client{BaseURL: srv.URL, Token: "tok", HTTP: srv.Client()}
+}
+
+func TestGet(t *testing.T) {
+	c := newTestClient(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.Method != nethttp.MethodGet || r.URL.Path != "/tok/get/V5" {
+			t.Errorf("request %s %s", r.Method, r.URL.Path)
+		}
+		io.WriteString(w, `["12.5","3"]`)
+	})
+
+	v, err := c.VirtualRead(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, Value{"12.5", "3"}) || v.String() != "12.5 3" {
+		t.Fatalf("value %q", v)
+	}
+	if f, err := v.Float(); err != nil || f != 12.5 {
+		t.Fatalf("Float() = %v, %v", f, err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	c := newTestClient(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path != "/tok/update/V1" || !reflect.DeepEqual(r.URL.Query()["value"], []string{"1", "2"}) {
+			t.Errorf("request %s", r.URL)
+		}
+	})
+	if err := c.VirtualWrite(1, "1", "2"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	c := newTestClient(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		var body map[string]string
+		if r.Method != nethttp.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["body"] != "hello" {
+			t.Errorf("body %v, %v", body, err)
+		}
+	})
+	if err := c.Notify("hello"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsHardwareConnected(t *testing.T) {
+	c := newTestClient(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, "true")
+	})
+	connected, err := c.IsHardwareConnected()
+	if err != nil || !connected {
+		t.Fatalf("IsHardwareConnected() = %v, %v", connected, err)
+	}
+}
+
+func TestProject(t *testing.T) {
+	c := newTestClient(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		io.WriteString(w, `{"id":1,"name":"Home","widgets":[{"id":2,"type":"BUTTON","pinType":"VIRTUAL","pin":5}],"extra":true}`)
+	})
+	p, err := c.Project()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Home" || len(p.Widgets) != 1 || p.Widgets[0].Pin != 5 || len(p.Raw) == 0 {
+		t.Fatalf("project %+v", p)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	tests := []struct {
+		status  int
+		message string
+		want    error
+	}{
+		{nethttp.StatusBadRequest, "Invalid token.", blynk.ErrInvalidToken},
+		{nethttp.StatusBadRequest, "invalid token", blynk.ErrInvalidToken},
+		{nethttp.StatusBadRequest, "Wrong pin format.", blynk.ErrIllegalCommandBody},
+		{nethttp.StatusUnauthorized, "Invalid token.", blynk.ErrInvalidToken},
+		{nethttp.StatusForbidden, "Invalid token.", blynk.ErrNotAllowed},
+		{nethttp.StatusNotFound, "Invalid token.", &blynk.StatusError{Code: blynk.BLYNK_NO_DATA}},
+		{nethttp.StatusTooManyRequests, "Invalid token.", blynk.ErrQuotaLimit},
+		{nethttp.StatusInternalServerError, "Invalid token.", blynk.ErrServerException},
+		{nethttp.StatusTeapot, "Invalid token.", blynk.ErrIllegalCommand},
+	}
+	for _, tt := range tests {
+		c := newTestClient(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			// only 400 is told apart by the message
+			nethttp.Error(w, tt.message, tt.status)
+		})
+		_, err := c.Get("V1")
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%d %q: error %v, want %v", tt.status, tt.message, err, tt.want)
+			continue
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Message != tt.message {
+			t.Errorf("%d: api error %+v", tt.status, apiErr)
+		}
+		if apiErr.Status.Command != blynk.BLYNK_CMD_HARDWARE_SYNC {
+			t.Errorf("%d: command %s", tt.status, apiErr.Status.Command)
+		}
+	}
+}
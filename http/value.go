@@ -0,0 +1,117 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	nethttp "net/http"
+	"strconv"
+	"strings"
+
+	blynk "github.com/OloloevReal/go-blynk"
+)
+
+// Value is the pin value returned by the server, multi-value pins have several elements
+type Value []string
+
+func (v Value) String() string {
+	return strings.Join(v, " ")
+}
+
+func (v Value) first() (string, error) {
+	if len(v) == 0 {
+		return "", fmt.Errorf("blynk http: empty value")
+	}
+	return v[0], nil
+}
+
+func (v Value) Int() (int, error) {
+	s, err := v.first()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(s)
+}
+
+func (v Value) Float() (float64, error) {
+	s, err := v.first()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// Bool accepts "1"/"0" used by buttons and digital pins as well as "true"/"false"
+func (v Value) Bool() (bool, error) {
+	s, err := v.first()
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(s)
+}
+
+type Widget struct {
+	ID      int64  `json:"id"`
+	Type    string `json:"type"`
+	Label   string `json:"label"`
+	PinType string `json:"pinType"`
+	Pin     int    `json:"pin"`
+	Value   string `json:"value"`
+}
+
+type ProjectDevice struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Board  string `json:"boardType"`
+	Status string `json:"status"`
+}
+
+// Project holds the common fields of the project JSON, Raw keeps the whole document
+type Project struct {
+	ID      int             `json:"id"`
+	Name    string          `json:"name"`
+	Widgets []Widget        `json:"widgets"`
+	Devices []ProjectDevice `json:"devices"`
+	Raw     json.RawMessage `json:"-"`
+}
+
+// APIError is returned for unsuccessful HTTP answers, errors.Is and errors.As match the wrapped *blynk.StatusError
+type APIError struct {
+	StatusCode int
+	Message    string
+	Status     *blynk.StatusError
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("blynk http: %d %s (%s)", e.StatusCode, e.Message, blynk.GetBlynkStatus(e.Status.Code))
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Status
+}
+
+// newAPIError maps the HTTP status to the status code of the hardware protocol, the message is kept as is.
+// 400 is also used for a wrong token, it is told apart from a bad request by the message
+func newAPIError(cmd blynk.BlynkCommand, statusCode int, message string) *APIError {
+	code := blynk.BLYNK_ILLEGAL_COMMAND
+	switch {
+	case statusCode == nethttp.StatusBadRequest && strings.Contains(strings.ToLower(message), "invalid token"):
+		code = blynk.BLYNK_INVALID_TOKEN
+	case statusCode == nethttp.StatusBadRequest:
+		code = blynk.BLYNK_ILLEGAL_COMMAND_BODY
+	case statusCode == nethttp.StatusUnauthorized:
+		code = blynk.BLYNK_INVALID_TOKEN
+	case statusCode == nethttp.StatusForbidden:
+		code = blynk.BLYNK_NOT_ALLOWED
+	case statusCode == nethttp.StatusNotFound:
+		code = blynk.BLYNK_NO_DATA
+	case statusCode == nethttp.StatusTooManyRequests:
+		code = blynk.BLYNK_QUOTA_LIMIT
+	case statusCode >= 500:
+		code = blynk.BLYNK_SERVER_EXCEPTION
+	}
+	return &APIError{
+		StatusCode: statusCode,
+		Message:    strings.TrimSpace(message),
+		Status:     &blynk.StatusError{Code: code, Command: cmd},
+	}
+}
@@ -0,0 +1,80 @@
+package blynk
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	LCD_COLUMNS = 16
+	LCD_ROWS    = 2
+)
+
+type LCD struct {
+	blynk  *Blynk
+	pin    int
+	lines  [LCD_ROWS]int
+	simple bool
+}
+
+// NewLCD returns LCD widget in advanced mode, the whole display is bound to one virtual pin
+func (g *Blynk) NewLCD(pin int) *LCD {
+	return &LCD{blynk: g, pin: pin}
+}
+
+// NewSimpleLCD returns LCD widget in simple mode, every line is bound to own virtual pin
+func (g *Blynk) NewSimpleLCD(line1 int, line2 int) *LCD {
+	return &LCD{blynk: g, lines: [LCD_ROWS]int{line1, line2}, simple: true}
+}
+
+func (l *LCD) Clear() error {
+	if l.simple {
+		return l.SetLines("", "")
+	}
+	return l.send(l.clearMessage())
+}
+
+func (l *LCD) Print(x int, y int, text string) error {
+	if l.simple {
+		return fmt.Errorf("lcd: print is not supported in simple mode")
+	}
+	if x < 0 || x >= LCD_COLUMNS || y < 0 || y >= LCD_ROWS {
+		return fmt.Errorf("lcd: position out of range, x-%d, y-%d", x, y)
+	}
+	return l.send(l.printMessage(x, y, text))
+}
+
+func (l *LCD) SetLines(line1 string, line2 string) error {
+	if !l.simple {
+		return fmt.Errorf("lcd: set lines is supported in simple mode only")
+	}
+	for _, msg := range l.linesMessages(line1, line2) {
+		if err := l.send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *LCD) send(msg BlynkMessage) error {
+	if l == nil || l.blynk == nil {
+		return fmt.Errorf("lcd: *LCD or *Blynk is nil")
+	}
+	_, err := l.blynk.sendMessage(msg)
+	return err
+}
+
+func (l *LCD) clearMessage() BlynkMessage {
+	return l.blynk.virtualWriteMessage(l.pin, "clr")
+}
+
+func (l *LCD) printMessage(x int, y int, text string) BlynkMessage {
+	return l.blynk.virtualWriteMessage(l.pin, "p", strconv.Itoa(x), strconv.Itoa(y), text)
+}
+
+func (l *LCD) linesMessages(line1 string, line2 string) []BlynkMessage {
+	return []BlynkMessage{
+		l.blynk.virtualWriteMessage(l.lines[0], line1),
+		l.blynk.virtualWriteMessage(l.lines[1], line2),
+	}
+}
@@ -0,0 +1,56 @@
+package blynk
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestLCDFrames(t *testing.T) {
+	tests := []struct {
+		name   string
+		simple bool
+		call   func(l *LCD) error
+		want   string
+	}{
+		{"advanced clear", false, func(l *LCD) error { return l.Clear() },
+			"14000100087677003100636c72"},
+		{"advanced print", false, func(l *LCD) error { return l.Print(0, 1, "Hi") },
+			"140001000d76770031007000300031004869"},
+		{"simple lines", true, func(l *LCD) error { return l.SetLines("line 1", "line 2") },
+			"140001000b76770032006c696e652031" + "140002000b76770033006c696e652032"},
+		{"simple clear", true, func(l *LCD) error { return l.Clear() },
+			"14000100057677003200" + "14000200057677003300"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, conn := newRecordBlynk()
+			l := g.NewLCD(1)
+			if tt.simple {
+				l = g.NewSimpleLCD(2, 3)
+			}
+			if err := tt.call(l); err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(conn.Take()); got != tt.want {
+				t.Errorf("frames\n got %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLCDErrors(t *testing.T) {
+	g, conn := newRecordBlynk()
+	if err := g.NewLCD(1).Print(LCD_COLUMNS, 0, "x"); err == nil {
+		t.Error("print out of range: expected error")
+	}
+	if err := g.NewLCD(1).SetLines("a", "b"); err == nil {
+		t.Error("set lines in advanced mode: expected error")
+	}
+	if err := g.NewSimpleLCD(2, 3).Print(0, 0, "x"); err == nil {
+		t.Error("print in simple mode: expected error")
+	}
+	if b := conn.Take(); len(b) != 0 {
+		t.Errorf("nothing should be sent, got % x", b)
+	}
+}
@@ -0,0 +1,70 @@
+package blynk
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Logger receives log lines of a device, every line starts with its level: [DEBUG], [INFO] or [ERROR]
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+// deviceLog adds the level to lines without it and drops debug lines unless debug is enabled for the device
+type deviceLog struct {
+	lock  sync.Mutex
+	out   Logger
+	debug bool
+}
+
+func newDeviceLog() *deviceLog {
+	return &deviceLog{out: stdLogger{}}
+}
+
+func (l *deviceLog) setOutput(out Logger) {
+	if out == nil {
+		out = stdLogger{}
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.out = out
+}
+
+func (l *deviceLog) setDebug(debug bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.debug = debug
+}
+
+// clone returns the log with the same output and debug flag, later changes of l do not affect it
+func (l *deviceLog) clone() *deviceLog {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return &deviceLog{out: l.out, debug: l.debug}
+}
+
+func (l *deviceLog) Printf(format string, v ...interface{}) {
+	level, msg := "INFO", fmt.Sprintf(format, v...)
+	for _, lv := range []string{"DEBUG", "INFO", "ERROR"} {
+		if strings.HasPrefix(msg, "["+lv+"]") {
+			level, msg = lv, strings.TrimPrefix(msg[len(lv)+2:], " ")
+			break
+		}
+	}
+
+	l.lock.Lock()
+	out, debug := l.out, l.debug
+	l.lock.Unlock()
+	if level == "DEBUG" && !debug {
+		return
+	}
+	out.Printf("[%s] %s", level, msg)
+}
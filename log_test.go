@@ -0,0 +1,25 @@
+package blynk
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeviceLog(t *testing.T) {
+	out := &lineLogger{}
+	l := newDeviceLog()
+	l.setOutput(out)
+	l.Printf("Timer: started")
+	l.Printf("[DEBUG] dropped")
+	l.Printf("[ERROR] failed, %s", "io")
+
+	debug := l.clone()
+	debug.setDebug(true)
+	debug.Printf("[DEBUG] kept")
+	l.Printf("[DEBUG] dropped again")
+
+	want := []string{"[INFO] Timer: started", "[ERROR] failed, io", "[DEBUG] kept"}
+	if got := out.Lines(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
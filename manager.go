@@ -0,0 +1,216 @@
+package blynk
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+type DeviceHealth struct {
+	Token     string
+	Connected bool
+	Connects  int
+	LastRTT   time.Duration
+	AvgRTT    time.Duration
+}
+
+type ManagerHealth struct {
+	Devices      int
+	Connected    int
+	Disconnected int
+	Details      []DeviceHealth
+}
+
+// Manager runs many devices in one process, devices share TLS config, dialer, metrics and the timer goroutine.
+// Every managed device uses one goroutine for receiving and processing of messages, heartbeats are sent
+// from short-lived goroutines. Timer callbacks run one by one on the shared goroutine and should not block.
+type Manager struct {
+	lock      sync.Mutex
+	devices   map[string]*Blynk
+	server    string
+	port      int
+	ssl       bool
+	tlsConfig *tls.Config
+	dialer    *net.Dialer
+	metrics   Metrics
+	clock     Clock
+	timer     *Timer
+	log       *deviceLog
+	cancel    chan bool
+	stopped   bool
+}
+
+func NewManager() *Manager {
+	m := &Manager{
+		devices: make(map[string]*Blynk),
+		server:  "blynk-cloud.com",
+		port:    443,
+		ssl:     true,
+		dialer:  &net.Dialer{Timeout: time.Second * 10, KeepAlive: time.Second * 30},
+		metrics: nopMetrics{},
+		clock:   realClock{},
+		log:     newDeviceLog(),
+		cancel:  make(chan bool),
+	}
+	m.timer = NewTimer(m.clock)
+	go m.timer.loop(m.cancel)
+	return m
+}
+
+// SetServer, SetTLSConfig, SetDialer, SetMetrics, SetLogger and SetDebug affect devices added after the call
+func (m *Manager) SetServer(server string, port int, ssl bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.server = server
+	m.port = port
+	m.ssl = ssl
+}
+
+func (m *Manager) SetTLSConfig(conf *tls.Config) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.tlsConfig = conf
+}
+
+func (m *Manager) SetDialer(dialer *net.Dialer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.dialer = dialer
+}
+
+func (m *Manager) SetMetrics(metrics Metrics) {
+	if metrics == nil {
+		metrics = nopMetrics{}
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.metrics = metrics
+}
+
+// SetLogger sends log lines of the manager and its devices to l, nil restores the standard logger
+func (m *Manager) SetLogger(l Logger) {
+	m.log.setOutput(l)
+}
+
+func (m *Manager) SetDebug(debug bool) {
+	m.log.setDebug(debug)
+}
+
+// Add creates device, calls setup for handlers registration, connects and starts processing.
+// Connect errors are returned, the device is not added in this case.
+func (m *Manager) Add(token string, setup func(device *Blynk)) (*Blynk, error) {
+	m.lock.Lock()
+	if m.stopped {
+		m.lock.Unlock()
+		return nil, fmt.Errorf("manager: stopped")
+	}
+	if _, ok := m.devices[token]; ok {
+		m.lock.Unlock()
+		return nil, fmt.Errorf("manager: device already exists")
+	}
+
+	g := NewBlynk(token)
+	g.DisableLogo(true)
+	g.SetServer(m.server, m.port, m.ssl)
+	g.SetTLSConfig(m.tlsConfig)
+	g.SetDialer(m.dialer)
+	g.SetMetrics(m.metrics)
+	g.clock = m.clock
+	g.timer = m.timer.Sub()
+	g.timer.Pause()
+	g.managed = true
+	g.log = m.log.clone()
+	m.devices[token] = g
+	m.lock.Unlock()
+
+	if setup != nil {
+		setup(g)
+	}
+
+	if err := g.Connect(); err != nil {
+		m.lock.Lock()
+		delete(m.devices, token)
+		m.lock.Unlock()
+		return nil, err
+	}
+	go g.Processing()
+	return g, nil
+}
+
+func (m *Manager) Remove(token string) error {
+	m.lock.Lock()
+	g, ok := m.devices[token]
+	delete(m.devices, token)
+	m.lock.Unlock()
+	if !ok {
+		return fmt.Errorf("manager: device not found")
+	}
+	return g.Shutdown()
+}
+
+func (m *Manager) Device(token string) *Blynk {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.devices[token]
+}
+
+func (m *Manager) Health() ManagerHealth {
+	m.lock.Lock()
+	devices := make([]*Blynk, 0, len(m.devices))
+	for _, g := range m.devices {
+		devices = append(devices, g)
+	}
+	m.lock.Unlock()
+
+	var h ManagerHealth
+	for _, g := range devices {
+		g.lock.Lock()
+		d := DeviceHealth{
+			Token:     g.APIkey,
+			Connected: g.connected,
+			Connects:  g.connects,
+			LastRTT:   g.lastRTT,
+			AvgRTT:    g.avgRTT,
+		}
+		g.lock.Unlock()
+
+		h.Devices++
+		if d.Connected {
+			h.Connected++
+		} else {
+			h.Disconnected++
+		}
+		h.Details = append(h.Details, d)
+	}
+	sort.Slice(h.Details, func(i, j int) bool { return h.Details[i].Token < h.Details[j].Token })
+	return h
+}
+
+// Stop stops all devices and the shared timer
+func (m *Manager) Stop() {
+	m.lock.Lock()
+	if m.stopped {
+		m.lock.Unlock()
+		return
+	}
+	m.stopped = true
+	devices := m.devices
+	m.devices = make(map[string]*Blynk)
+	m.lock.Unlock()
+
+	var wg sync.WaitGroup
+	for _, g := range devices {
+		wg.Add(1)
+		go func(g *Blynk) {
+			defer wg.Done()
+			if err := g.Shutdown(); err != nil {
+				m.log.Printf("[ERROR] manager: stop failed, %s", err.Error())
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(m.cancel)
+}
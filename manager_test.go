@@ -0,0 +1,194 @@
+package blynk
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OloloevReal/go-blynk/server"
+)
+
+// blockConn blocks writes until release is closed
+type blockConn struct {
+	*recordConn
+	writes  chan struct{}
+	release chan struct{}
+}
+
+func (c *blockConn) Write(b []byte) (int, error) {
+	c.writes <- struct{}{}
+	<-c.release
+	return len(b), nil
+}
+
+var _ net.Conn = (*blockConn)(nil)
+
+func TestManagedHeartbeatDoesNotBlockTimer(t *testing.T) {
+	clock := newFakeClock()
+	shared := NewTimer(clock)
+
+	stuck := NewBlynk("stuck")
+	conn := &blockConn{recordConn: newRecordConn(), writes: make(chan struct{}, 10), release: make(chan struct{})}
+	stuck.conn = conn
+	stuck.setConnected(true)
+	stuck.timer = shared.Sub()
+	stuck.timer.SetInterval(time.Second, stuck.heartbeatAsync)
+
+	other := 0
+	shared.Sub().SetInterval(time.Second, func() { other++ })
+
+	for i := 1; i <= 3; i++ {
+		clock.Advance(time.Second)
+		done := make(chan struct{})
+		go func() {
+			shared.Run()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("blocked write stalls the shared timer")
+		}
+		if other != i {
+			t.Fatalf("other device timer ran %d times, want %d", other, i)
+		}
+	}
+
+	// only the first heartbeat is writing, the next ones are skipped
+	<-conn.writes
+	select {
+	case <-conn.writes:
+		t.Fatal("heartbeat is started while the previous one is blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(conn.release)
+}
+
+// lineLogger keeps log lines
+type lineLogger struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (l *lineLogger) Printf(format string, v ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func (l *lineLogger) Lines() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+func startManagerServer(t *testing.T, tokens ...string) (*server.Server, int) {
+	t.Helper()
+	store := server.NewStore()
+	for _, token := range tokens {
+		store.AddDevice(server.Device{Token: token})
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.NewServer(store)
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return srv, ln.Addr().(*net.TCPAddr).Port
+}
+
+func waitOnline(t *testing.T, srv *server.Server, token string, online bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if (len(srv.Online(token)) > 0) == online {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("device %s online: %v, want %v", token, !online, online)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestManagerAddRemoveHealth(t *testing.T) {
+	srv, port := startManagerServer(t, "lamp", "switch")
+	m := NewManager()
+	defer m.Stop()
+	m.SetServer("127.0.0.1", port, false)
+	logger := &lineLogger{}
+	m.SetLogger(logger)
+
+	var setups []string
+	setup := func(device *Blynk) { setups = append(setups, device.APIkey) }
+	for _, token := range []string{"switch", "lamp"} {
+		if _, err := m.Add(token, setup); err != nil {
+			t.Fatalf("add %s: %v", token, err)
+		}
+		waitOnline(t, srv, token, true)
+	}
+	if strings.Join(setups, ",") != "switch,lamp" {
+		t.Fatalf("setup called for %v", setups)
+	}
+	if _, err := m.Add("lamp", nil); err == nil {
+		t.Fatal("device is added twice")
+	}
+	if _, err := m.Add("unknown", nil); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("add with unknown token: %v, want ErrInvalidToken", err)
+	}
+	if m.Device("unknown") != nil {
+		t.Fatal("device with failed connect is kept")
+	}
+
+	h := m.Health()
+	if h.Devices != 2 || h.Connected != 2 || h.Disconnected != 0 {
+		t.Fatalf("health %+v, want 2 connected devices", h)
+	}
+	if h.Details[0].Token != "lamp" || h.Details[1].Token != "switch" {
+		t.Fatalf("details are not sorted by token: %+v", h.Details)
+	}
+	for _, d := range h.Details {
+		if !d.Connected || d.Connects != 1 {
+			t.Fatalf("device health %+v, want connected once", d)
+		}
+	}
+
+	start := time.Now()
+	if err := m.Remove("lamp"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("remove took %v", elapsed)
+	}
+	if err := m.Remove("lamp"); err == nil {
+		t.Fatal("removed device is removed again")
+	}
+	waitOnline(t, srv, "lamp", false)
+	if h := m.Health(); h.Devices != 1 || h.Details[0].Token != "switch" {
+		t.Fatalf("health after remove %+v", h)
+	}
+
+	start = time.Now()
+	m.Stop()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("stop took %v", elapsed)
+	}
+	waitOnline(t, srv, "switch", false)
+	if _, err := m.Add("lamp", nil); err == nil {
+		t.Fatal("device is added to the stopped manager")
+	}
+
+	if len(logger.Lines()) == 0 {
+		t.Fatal("devices do not log to the manager logger")
+	}
+	for _, line := range logger.Lines() {
+		if strings.HasPrefix(line, "[DEBUG]") {
+			t.Fatalf("debug line without SetDebug: %q", line)
+		}
+	}
+}
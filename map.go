@@ -0,0 +1,79 @@
+package blynk
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type MapWidget struct {
+	blynk *Blynk
+	pin   int
+}
+
+type GPSLocation struct {
+	Lat   float64
+	Lon   float64
+	Alt   float64
+	Speed float64
+}
+
+func (g *Blynk) NewMap(pin int) *MapWidget {
+	return &MapWidget{blynk: g, pin: pin}
+}
+
+func (m *MapWidget) Add(index int, lat float64, lon float64, label string) error {
+	_, err := m.blynk.sendMessage(m.addMessage(index, lat, lon, label))
+	return err
+}
+
+func (m *MapWidget) Clear() error {
+	return m.blynk.VirtualWrite(m.pin, "clr")
+}
+
+func (m *MapWidget) addMessage(index int, lat float64, lon float64, label string) BlynkMessage {
+	msg := BlynkMessage{}
+	msg.Head.Command = BLYNK_CMD_HARDWARE
+	msg.Head.MessageId = m.blynk.getMessageID()
+	msg.Body.AddString("vw")
+	msg.Body.AddInt(m.pin, index)
+	msg.Body.AddFloat(lat, lon)
+	msg.Body.AddString(label)
+	msg.Head.Length = msg.Body.Len()
+	return msg
+}
+
+// AddGPSHandler subscribes to GPS Stream widget, the widget sends lat, lon, alt and speed in one message
+func (g *Blynk) AddGPSHandler(pin uint, fn func(pin uint, loc GPSLocation)) {
+	g.setValuesHandler(pin, func(pin uint, values []string) {
+		loc, err := parseGPSLocation(values)
+		if err != nil {
+			g.log.Printf("[ERROR] gps: %s, Pin: %d", err.Error(), pin)
+			return
+		}
+		fn(pin, loc)
+	})
+}
+
+// DeleteGPSHandler removes the GPS handler of the pin
+func (g *Blynk) DeleteGPSHandler(pin uint) {
+	g.DeleteTypedHandler(pin)
+}
+
+func parseGPSLocation(values []string) (GPSLocation, error) {
+	var loc GPSLocation
+	if len(values) < 2 {
+		return loc, fmt.Errorf("expected at least lat and lon, got %d values", len(values))
+	}
+	fields := []*float64{&loc.Lat, &loc.Lon, &loc.Alt, &loc.Speed}
+	for i, v := range values {
+		if i >= len(fields) {
+			break
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return loc, fmt.Errorf("bad value %q", v)
+		}
+		*fields[i] = f
+	}
+	return loc, nil
+}
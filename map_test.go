@@ -0,0 +1,77 @@
+package blynk
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMapFrames(t *testing.T) {
+	g, conn := newRecordBlynk()
+	m := g.NewMap(4)
+	if err := m.Add(0, 50.4501, 30.5234, "Home"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add(12, -33.8688, 151.2093, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"vw\x004\x000\x0050.4501\x0030.5234\x00Home",
+		"vw\x004\x0012\x00-33.8688\x00151.2093\x00",
+		"vw\x004\x00clr",
+	}
+	if got := frameBodies(conn.Take()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("bodies\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestParseGPSLocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   GPSLocation
+		err    bool
+	}{
+		{"full", []string{"50.45", "30.52", "179", "3.5"}, GPSLocation{Lat: 50.45, Lon: 30.52, Alt: 179, Speed: 3.5}, false},
+		{"lat lon", []string{"-1.5", "2"}, GPSLocation{Lat: -1.5, Lon: 2}, false},
+		{"extra values", []string{"1", "2", "3", "4", "5"}, GPSLocation{Lat: 1, Lon: 2, Alt: 3, Speed: 4}, false},
+		{"empty", nil, GPSLocation{}, true},
+		{"short", []string{"50.45"}, GPSLocation{}, true},
+		{"bad lat", []string{"north", "30.52"}, GPSLocation{}, true},
+		{"bad speed", []string{"1", "2", "3", "fast"}, GPSLocation{}, true},
+		{"empty field", []string{"1", ""}, GPSLocation{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseGPSLocation(tt.values)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGPSHandler(t *testing.T) {
+	g, _ := newRecordBlynk()
+	var got []GPSLocation
+	g.AddGPSHandler(6, func(pin uint, loc GPSLocation) {
+		got = append(got, loc)
+	})
+
+	g.handleHardware(&BlynkRespose{Command: BLYNK_CMD_HARDWARE, Values: []string{"vw", "6", "1"}})
+	g.handleHardware(&BlynkRespose{Command: BLYNK_CMD_HARDWARE, Values: []string{"vw", "6", "1", "2"}})
+	if want := []GPSLocation{{Lat: 1, Lon: 2}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("locations %+v, want %+v", got, want)
+	}
+
+	g.DeleteGPSHandler(6)
+	g.handleHardware(&BlynkRespose{Command: BLYNK_CMD_HARDWARE, Values: []string{"vw", "6", "3", "4"}})
+	if len(got) != 1 {
+		t.Fatalf("handler is called after delete, %+v", got)
+	}
+}
@@ -0,0 +1,40 @@
+package blynk
+
+import (
+	"time"
+)
+
+// Metrics receives events of the connection, it must be safe for concurrent use
+type Metrics interface {
+	MessageSent(cmd BlynkCommand, bytes int)
+	MessageReceived(cmd BlynkCommand, bytes int)
+	Reconnect()
+	AuthFailure()
+	PingRTT(rtt time.Duration)
+	QueueDepth(depth int)
+	HandlerDuration(pin uint, d time.Duration)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) MessageSent(BlynkCommand, int)       {}
+func (nopMetrics) MessageReceived(BlynkCommand, int)   {}
+func (nopMetrics) Reconnect()                          {}
+func (nopMetrics) AuthFailure()                        {}
+func (nopMetrics) PingRTT(time.Duration)               {}
+func (nopMetrics) QueueDepth(int)                      {}
+func (nopMetrics) HandlerDuration(uint, time.Duration) {}
+
+func (g *Blynk) SetMetrics(m Metrics) {
+	if m == nil {
+		m = nopMetrics{}
+	}
+	g.metrics = m
+}
+
+func frameSize(resp *BlynkRespose) int {
+	if resp.Command == BLYNK_CMD_RESPONSE {
+		return BLYNK_HEAD_SIZE
+	}
+	return BLYNK_HEAD_SIZE + int(resp.Status)
+}
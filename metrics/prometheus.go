@@ -0,0 +1,207 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	blynk "github.com/OloloevReal/go-blynk"
+	protocol "github.com/OloloevReal/go-blynk/protocol"
+)
+
+const contentTypeText = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	RTTBuckets     = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+	HandlerBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+)
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Prometheus implements blynk.Metrics and serves the values in Prometheus text format
+type Prometheus struct {
+	lock          sync.Mutex
+	sent          map[protocol.Command]uint64
+	received      map[protocol.Command]uint64
+	bytesSent     uint64
+	bytesReceived uint64
+	reconnects    uint64
+	authFailures  uint64
+	queueDepth    int
+	pingRTT       *histogram
+	handlers      map[uint]*histogram
+}
+
+var _ blynk.Metrics = (*Prometheus)(nil)
+
+func NewPrometheus() *Prometheus {
+	return &Prometheus{
+		sent:     make(map[protocol.Command]uint64),
+		received: make(map[protocol.Command]uint64),
+		pingRTT:  newHistogram(RTTBuckets),
+		handlers: make(map[uint]*histogram),
+	}
+}
+
+func (p *Prometheus) MessageSent(cmd protocol.Command, bytes int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.sent[cmd]++
+	p.bytesSent += uint64(bytes)
+}
+
+func (p *Prometheus) MessageReceived(cmd protocol.Command, bytes int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.received[cmd]++
+	p.bytesReceived += uint64(bytes)
+}
+
+func (p *Prometheus) Reconnect() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.reconnects++
+}
+
+func (p *Prometheus) AuthFailure() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.authFailures++
+}
+
+func (p *Prometheus) PingRTT(rtt time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.pingRTT.observe(rtt.Seconds())
+}
+
+func (p *Prometheus) QueueDepth(depth int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.queueDepth = depth
+}
+
+func (p *Prometheus) HandlerDuration(pin uint, d time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	h, ok := p.handlers[pin]
+	if !ok {
+		h = newHistogram(HandlerBuckets)
+		p.handlers[pin] = h
+	}
+	h.observe(d.Seconds())
+}
+
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentTypeText)
+	p.WriteTo(w)
+}
+
+// WriteTo writes all metrics in Prometheus text exposition format
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	ew := &errWriter{w: w}
+	writeCommandCounter(ew, "blynk_messages_sent_total", "Messages sent to the server.", p.sent)
+	writeCommandCounter(ew, "blynk_messages_received_total", "Messages received from the server.", p.received)
+	writeMetric(ew, "blynk_bytes_sent_total", "counter", "Bytes sent to the server.", p.bytesSent)
+	writeMetric(ew, "blynk_bytes_received_total", "counter", "Bytes received from the server.", p.bytesReceived)
+	writeMetric(ew, "blynk_reconnects_total", "counter", "Successful connects after the first one.", p.reconnects)
+	writeMetric(ew, "blynk_auth_failures_total", "counter", "Failed logins.", p.authFailures)
+	writeMetric(ew, "blynk_receive_queue_depth", "gauge", "Received messages waiting for processing.", p.queueDepth)
+
+	ew.printf("# HELP blynk_ping_rtt_seconds Round trip time of keep-alive pings.\n")
+	ew.printf("# TYPE blynk_ping_rtt_seconds histogram\n")
+	writeHistogram(ew, "blynk_ping_rtt_seconds", "", p.pingRTT)
+
+	ew.printf("# HELP blynk_handler_duration_seconds Duration of pin handlers.\n")
+	ew.printf("# TYPE blynk_handler_duration_seconds histogram\n")
+	pins := make([]int, 0, len(p.handlers))
+	for pin := range p.handlers {
+		pins = append(pins, int(pin))
+	}
+	sort.Ints(pins)
+	for _, pin := range pins {
+		writeHistogram(ew, "blynk_handler_duration_seconds", fmt.Sprintf(`pin="%d"`, pin), p.handlers[uint(pin)])
+	}
+	return ew.n, ew.err
+}
+
+func writeMetric(ew *errWriter, name string, kind string, help string, v interface{}) {
+	ew.printf("# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, v)
+}
+
+func writeCommandCounter(ew *errWriter, name string, help string, values map[protocol.Command]uint64) {
+	ew.printf("# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	cmds := make([]int, 0, len(values))
+	for cmd := range values {
+		cmds = append(cmds, int(cmd))
+	}
+	sort.Ints(cmds)
+	for _, cmd := range cmds {
+		ew.printf("%s{command=\"%s\"} %d\n", name, protocol.Command(cmd), values[protocol.Command(cmd)])
+	}
+}
+
+func writeHistogram(ew *errWriter, name string, labels string, h *histogram) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	for i, b := range h.buckets {
+		ew.printf("%s_bucket{%s%sle=\"%s\"} %d\n", name, labels, sep, formatFloat(b), h.counts[i])
+	}
+	ew.printf("%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	ew.printf("%s_sum%s %s\n", name, labels, formatFloat(h.sum))
+	ew.printf("%s_count%s %d\n", name, labels, h.count)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type errWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...interface{}) {
+	if e.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(e.w, format, args...)
+	e.n += int64(n)
+	e.err = err
+}
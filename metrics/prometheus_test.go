@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	protocol "github.com/OloloevReal/go-blynk/protocol"
+)
+
+func TestPrometheusCommandLabels(t *testing.T) {
+	p := NewPrometheus()
+	p.MessageReceived(protocol.CMD_HARDWARE, 10)
+	p.MessageReceived(protocol.Command(40), 5)
+	p.MessageReceived(protocol.Command(41), 5)
+	p.MessageReceived(protocol.Command(99), 5)
+
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`blynk_messages_received_total{command="HARDWARE"} 1`,
+		`blynk_messages_received_total{command="GET_SERVER"} 1`,
+		`blynk_messages_received_total{command="REDIRECT"} 1`,
+		`blynk_messages_received_total{command="CMD_99"} 1`,
+		"blynk_bytes_received_total 25",
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(out, "UNDEFINED") {
+		t.Error("unknown commands share the UNDEFINED label")
+	}
+}
+
+func TestPrometheusHistograms(t *testing.T) {
+	p := NewPrometheus()
+	p.PingRTT(30 * time.Millisecond)
+	p.HandlerDuration(5, 2*time.Millisecond)
+
+	var buf bytes.Buffer
+	p.WriteTo(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		`blynk_ping_rtt_seconds_bucket{le="0.025"} 0`,
+		`blynk_ping_rtt_seconds_bucket{le="0.05"} 1`,
+		`blynk_ping_rtt_seconds_count 1`,
+		`blynk_handler_duration_seconds_bucket{pin="5",le="0.005"} 1`,
+		`blynk_handler_duration_seconds_count{pin="5"} 1`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
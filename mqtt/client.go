@@ -0,0 +1,294 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	slog "github.com/OloloevReal/go-simple-log"
+)
+
+var ErrClosed = errors.New("mqtt: connection closed")
+
+type Options struct {
+	ClientID     string
+	Username     string
+	Password     string
+	KeepAlive    time.Duration
+	CleanSession bool
+	Timeout      time.Duration
+}
+
+type subscription struct {
+	filter string
+	fn     func(*Message)
+}
+
+// Client is a minimal MQTT 3.1.1 client with QoS 0 and 1 support
+type Client struct {
+	conn    net.Conn
+	opts    Options
+	lock    sync.Mutex
+	wlock   sync.Mutex
+	lastID  uint16
+	pending map[uint16]chan *Packet
+	subs    []subscription
+	done    chan struct{}
+	err     error
+}
+
+func Dial(addr string, opts Options) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, opts.timeout())
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClient(conn, opts)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient sends CONNECT over conn, waits for CONNACK and starts the reader goroutine
+func NewClient(conn net.Conn, opts Options) (*Client, error) {
+	c := &Client{
+		conn:    conn,
+		opts:    opts,
+		pending: make(map[uint16]chan *Packet),
+		done:    make(chan struct{}),
+	}
+
+	r := bufio.NewReader(conn)
+	if err := c.connect(r); err != nil {
+		return nil, err
+	}
+
+	go c.reader(r)
+	if opts.KeepAlive > 0 {
+		go c.keepAlive()
+	}
+	return c, nil
+}
+
+func (o Options) timeout() time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return time.Second * 10
+}
+
+func (c *Client) connect(r *bufio.Reader) error {
+	var flags byte
+	if c.opts.CleanSession {
+		flags |= 0x02
+	}
+	if c.opts.Username != "" {
+		flags |= 0x80
+	}
+	if c.opts.Password != "" {
+		flags |= 0x40
+	}
+
+	payload := appendString(nil, "MQTT")
+	payload = append(payload, 0x04, flags)
+	payload = binary.BigEndian.AppendUint16(payload, uint16(c.opts.KeepAlive/time.Second))
+	payload = appendString(payload, c.opts.ClientID)
+	if c.opts.Username != "" {
+		payload = appendString(payload, c.opts.Username)
+	}
+	if c.opts.Password != "" {
+		payload = appendString(payload, c.opts.Password)
+	}
+
+	c.conn.SetDeadline(time.Now().Add(c.opts.timeout()))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if err := c.write(&Packet{Type: CONNECT, Payload: payload}); err != nil {
+		return err
+	}
+	p, err := ReadPacket(r)
+	if err != nil {
+		return err
+	}
+	if p.Type != CONNACK || len(p.Payload) < 2 {
+		return fmt.Errorf("mqtt: expected CONNACK, got packet type %d", p.Type)
+	}
+	if p.Payload[1] != 0 {
+		return fmt.Errorf("mqtt: connection refused, code %d", p.Payload[1])
+	}
+	return nil
+}
+
+func (c *Client) write(p *Packet) error {
+	c.wlock.Lock()
+	defer c.wlock.Unlock()
+	return WritePacket(c.conn, p)
+}
+
+func (c *Client) nextID() (uint16, chan *Packet) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for {
+		c.lastID++
+		if c.lastID == 0 {
+			continue
+		}
+		if _, ok := c.pending[c.lastID]; !ok {
+			break
+		}
+	}
+	ch := make(chan *Packet, 1)
+	c.pending[c.lastID] = ch
+	return c.lastID, ch
+}
+
+func (c *Client) wait(id uint16, ch chan *Packet) (*Packet, error) {
+	defer func() {
+		c.lock.Lock()
+		delete(c.pending, id)
+		c.lock.Unlock()
+	}()
+	select {
+	case p := <-ch:
+		return p, nil
+	case <-c.done:
+		return nil, ErrClosed
+	case <-time.After(c.opts.timeout()):
+		return nil, fmt.Errorf("mqtt: timeout waiting for ack of packet %d", id)
+	}
+}
+
+// Publish sends the message, QoS 1 waits for PUBACK
+func (c *Client) Publish(topic string, payload []byte, qos byte, retain bool) error {
+	if qos > 1 {
+		return fmt.Errorf("mqtt: qos %d is not supported", qos)
+	}
+	m := &Message{Topic: topic, Payload: payload, QoS: qos, Retain: retain}
+	if qos == 0 {
+		return c.write(EncodePublish(m))
+	}
+
+	id, ch := c.nextID()
+	m.PacketID = id
+	if err := c.write(EncodePublish(m)); err != nil {
+		return err
+	}
+	_, err := c.wait(id, ch)
+	return err
+}
+
+// Subscribe registers fn for the filter and waits for SUBACK, fn is called on the reader goroutine
+func (c *Client) Subscribe(filter string, qos byte, fn func(*Message)) error {
+	if qos > 1 {
+		qos = 1
+	}
+	c.lock.Lock()
+	c.subs = append(c.subs, subscription{filter: filter, fn: fn})
+	c.lock.Unlock()
+
+	id, ch := c.nextID()
+	payload := idPacket(SUBSCRIBE, 0x02, id).Payload
+	payload = appendString(payload, filter)
+	payload = append(payload, qos)
+	if err := c.write(&Packet{Type: SUBSCRIBE, Flags: 0x02, Payload: payload}); err != nil {
+		return err
+	}
+	p, err := c.wait(id, ch)
+	if err != nil {
+		return err
+	}
+	if len(p.Payload) < 3 || p.Payload[2] == 0x80 {
+		return fmt.Errorf("mqtt: subscription to %q refused", filter)
+	}
+	return nil
+}
+
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+func (c *Client) Close() error {
+	c.write(&Packet{Type: DISCONNECT})
+	return c.conn.Close()
+}
+
+func (c *Client) reader(r *bufio.Reader) {
+	defer close(c.done)
+	for {
+		p, err := ReadPacket(r)
+		if err != nil {
+			c.lock.Lock()
+			c.err = err
+			c.lock.Unlock()
+			return
+		}
+
+		switch p.Type {
+		case PUBLISH:
+			m, err := DecodePublish(p)
+			if err != nil {
+				slog.Printf("[ERROR] mqtt: %s", err.Error())
+				continue
+			}
+			if m.QoS == 1 {
+				c.write(idPacket(PUBACK, 0, m.PacketID))
+			}
+			c.dispatch(m)
+		case PUBACK, SUBACK, UNSUBACK:
+			id, err := packetID(p)
+			if err != nil {
+				slog.Printf("[ERROR] mqtt: %s", err.Error())
+				continue
+			}
+			c.lock.Lock()
+			ch, ok := c.pending[id]
+			c.lock.Unlock()
+			if ok {
+				ch <- p
+			}
+		case PINGRESP:
+		default:
+			slog.Printf("[DEBUG] mqtt: unhandled packet type %d", p.Type)
+		}
+	}
+}
+
+func (c *Client) dispatch(m *Message) {
+	c.lock.Lock()
+	var fns []func(*Message)
+	for _, s := range c.subs {
+		if Match(s.filter, m.Topic) {
+			fns = append(fns, s.fn)
+		}
+	}
+	c.lock.Unlock()
+	for _, fn := range fns {
+		fn(m)
+	}
+}
+
+func (c *Client) keepAlive() {
+	t := time.NewTicker(c.opts.KeepAlive / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := c.write(&Packet{Type: PINGREQ}); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeBroker is the server side of net.Pipe, the test reads and answers packets by hand
+type fakeBroker struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (b *fakeBroker) expect(typ byte) *Packet {
+	b.t.Helper()
+	b.conn.SetReadDeadline(time.Now().Add(time.Second))
+	p, err := ReadPacket(b.r)
+	if err != nil {
+		b.t.Fatalf("broker: read failed, %s", err.Error())
+	}
+	if p.Type != typ {
+		b.t.Fatalf("broker: got packet type %d, want %d", p.Type, typ)
+	}
+	return p
+}
+
+func (b *fakeBroker) send(p *Packet) {
+	b.t.Helper()
+	b.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	if err := WritePacket(b.conn, p); err != nil {
+		b.t.Fatalf("broker: write failed, %s", err.Error())
+	}
+}
+
+// close expects DISCONNECT sent by Close of the client
+func (b *fakeBroker) close(c *Client) {
+	b.t.Helper()
+	go c.Close()
+	b.expect(DISCONNECT)
+}
+
+// dialPipe connects a client to the fake broker, the broker answers CONNECT with code
+func dialPipe(t *testing.T, opts Options, code byte) (*Client, *fakeBroker, *Packet, error) {
+	server, client := net.Pipe()
+	b := &fakeBroker{t: t, conn: server, r: bufio.NewReader(server)}
+	t.Cleanup(func() { server.Close() })
+
+	connect := make(chan *Packet, 1)
+	go func() {
+		p, err := ReadPacket(b.r)
+		if err != nil {
+			close(connect)
+			return
+		}
+		connect <- p
+		WritePacket(server, &Packet{Type: CONNACK, Payload: []byte{0x00, code}})
+	}()
+
+	c, err := NewClient(client, opts)
+	return c, b, <-connect, err
+}
+
+func TestConnect(t *testing.T) {
+	opts := Options{ClientID: "blynk", Username: "user", Password: "secret", KeepAlive: 30 * time.Second, CleanSession: true}
+	c, b, p, err := dialPipe(t, opts, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.close(c)
+
+	want := appendString(nil, "MQTT")
+	want = append(want, 0x04, 0xC2)
+	want = binary.BigEndian.AppendUint16(want, 30)
+	want = appendString(want, "blynk")
+	want = appendString(want, "user")
+	want = appendString(want, "secret")
+	if !bytes.Equal(p.Payload, want) {
+		t.Fatalf("CONNECT payload % x, want % x", p.Payload, want)
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	if _, _, _, err := dialPipe(t, Options{ClientID: "blynk"}, 5); err == nil {
+		t.Fatal("refused connection is accepted")
+	}
+}
+
+func TestPublish(t *testing.T) {
+	c, b, _, err := dialPipe(t, Options{ClientID: "blynk"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.close(c)
+
+	go c.Publish("blynk/V1", []byte("1"), 0, true)
+	p := b.expect(PUBLISH)
+	m, err := DecodePublish(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Topic != "blynk/V1" || string(m.Payload) != "1" || m.QoS != 0 || !m.Retain {
+		t.Fatalf("qos 0 message %+v", m)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.Publish("blynk/V2", []byte("on"), 1, false) }()
+	m, err = DecodePublish(b.expect(PUBLISH))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.QoS != 1 || m.PacketID == 0 || string(m.Payload) != "on" {
+		t.Fatalf("qos 1 message %+v", m)
+	}
+	select {
+	case <-done:
+		t.Fatal("qos 1 publish returned before PUBACK")
+	case <-time.After(20 * time.Millisecond):
+	}
+	b.send(idPacket(PUBACK, 0, m.PacketID))
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Publish("blynk/V3", nil, 2, false); err == nil {
+		t.Fatal("qos 2 is accepted")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	c, b, _, err := dialPipe(t, Options{ClientID: "blynk"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.close(c)
+
+	received := make(chan *Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Subscribe("blynk/+/set", 1, func(m *Message) { received <- m })
+	}()
+	p := b.expect(SUBSCRIBE)
+	if p.Flags != 0x02 {
+		t.Fatalf("SUBSCRIBE flags %x, want 2", p.Flags)
+	}
+	id, _ := packetID(p)
+	filter, rest, err := readString(p.Payload[2:])
+	if err != nil || filter != "blynk/+/set" || !bytes.Equal(rest, []byte{1}) {
+		t.Fatalf("SUBSCRIBE filter %q, qos % x", filter, rest)
+	}
+	b.send(&Packet{Type: SUBACK, Payload: append(binary.BigEndian.AppendUint16(nil, id), 1)})
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// qos 1 message from the broker is acknowledged and dispatched
+	b.send(EncodePublish(&Message{Topic: "blynk/V1/set", Payload: []byte("1"), QoS: 1, PacketID: 9}))
+	ack := b.expect(PUBACK)
+	if ackID, _ := packetID(ack); ackID != 9 {
+		t.Fatalf("PUBACK id %d, want 9", ackID)
+	}
+	select {
+	case m := <-received:
+		if m.Topic != "blynk/V1/set" || string(m.Payload) != "1" {
+			t.Fatalf("received %+v", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message is not dispatched")
+	}
+
+	// messages of other topics are not dispatched
+	b.send(EncodePublish(&Message{Topic: "blynk/V1/state", Payload: []byte("1")}))
+	select {
+	case m := <-received:
+		t.Fatalf("received %+v", m)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestSubscribeRefused(t *testing.T) {
+	c, b, _, err := dialPipe(t, Options{ClientID: "blynk"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.close(c)
+
+	done := make(chan error, 1)
+	go func() { done <- c.Subscribe("#", 0, func(*Message) {}) }()
+	id, _ := packetID(b.expect(SUBSCRIBE))
+	b.send(&Packet{Type: SUBACK, Payload: append(binary.BigEndian.AppendUint16(nil, id), 0x80)})
+	if err := <-done; err == nil {
+		t.Fatal("refused subscription is accepted")
+	}
+}
+
+func TestClosedConnection(t *testing.T) {
+	c, b, _, err := dialPipe(t, Options{ClientID: "blynk"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.Publish("blynk/V1", []byte("1"), 1, false) }()
+	b.expect(PUBLISH)
+	b.conn.Close()
+	if err := <-done; err != ErrClosed {
+		t.Fatalf("error %v, want ErrClosed", err)
+	}
+	<-c.Done()
+}
@@ -0,0 +1,199 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	CONNECT     byte = 1
+	CONNACK     byte = 2
+	PUBLISH     byte = 3
+	PUBACK      byte = 4
+	SUBSCRIBE   byte = 8
+	SUBACK      byte = 9
+	UNSUBSCRIBE byte = 10
+	UNSUBACK    byte = 11
+	PINGREQ     byte = 12
+	PINGRESP    byte = 13
+	DISCONNECT  byte = 14
+)
+
+const maxRemainingLength = 268435455
+
+// maxReadLength limits packets read from the broker, the length comes from the network before the payload
+const maxReadLength = 1 << 20
+
+var ErrMalformedPacket = errors.New("mqtt: malformed packet")
+var ErrPacketTooLarge = errors.New("mqtt: packet too large")
+
+// Packet is a raw control packet, Flags are the low 4 bits of the fixed header
+type Packet struct {
+	Type    byte
+	Flags   byte
+	Payload []byte
+}
+
+func WritePacket(w io.Writer, p *Packet) error {
+	if len(p.Payload) > maxRemainingLength {
+		return ErrPacketTooLarge
+	}
+	buf := make([]byte, 0, 5+len(p.Payload))
+	buf = append(buf, p.Type<<4|p.Flags&0x0F)
+	buf = appendRemainingLength(buf, len(p.Payload))
+	buf = append(buf, p.Payload...)
+	_, err := w.Write(buf)
+	return err
+}
+
+func ReadPacket(r *bufio.Reader) (*Packet, error) {
+	h, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length, err := readRemainingLength(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxReadLength {
+		return nil, ErrPacketTooLarge
+	}
+	p := &Packet{Type: h >> 4, Flags: h & 0x0F, Payload: make([]byte, length)}
+	if _, err := io.ReadFull(r, p.Payload); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func appendRemainingLength(buf []byte, n int) []byte {
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if n == 0 {
+			return buf
+		}
+	}
+}
+
+func readRemainingLength(r *bufio.Reader) (int, error) {
+	n, mult := 0, 1
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n += int(b&0x7F) * mult
+		if b&0x80 == 0 {
+			return n, nil
+		}
+		mult *= 128
+	}
+	return 0, ErrMalformedPacket
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+func readString(buf []byte) (string, []byte, error) {
+	if len(buf) < 2 {
+		return "", nil, ErrMalformedPacket
+	}
+	n := int(binary.BigEndian.Uint16(buf))
+	if len(buf) < 2+n {
+		return "", nil, ErrMalformedPacket
+	}
+	return string(buf[2 : 2+n]), buf[2+n:], nil
+}
+
+type Message struct {
+	Topic    string
+	Payload  []byte
+	QoS      byte
+	Retain   bool
+	PacketID uint16
+}
+
+func EncodePublish(m *Message) *Packet {
+	flags := m.QoS << 1
+	if m.Retain {
+		flags |= 0x01
+	}
+	payload := appendString(nil, m.Topic)
+	if m.QoS > 0 {
+		payload = binary.BigEndian.AppendUint16(payload, m.PacketID)
+	}
+	payload = append(payload, m.Payload...)
+	return &Packet{Type: PUBLISH, Flags: flags, Payload: payload}
+}
+
+func DecodePublish(p *Packet) (*Message, error) {
+	m := &Message{QoS: (p.Flags >> 1) & 0x03, Retain: p.Flags&0x01 != 0}
+	topic, rest, err := readString(p.Payload)
+	if err != nil {
+		return nil, err
+	}
+	m.Topic = topic
+	if m.QoS > 0 {
+		if len(rest) < 2 {
+			return nil, ErrMalformedPacket
+		}
+		m.PacketID = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	m.Payload = rest
+	return m, nil
+}
+
+func packetID(p *Packet) (uint16, error) {
+	if len(p.Payload) < 2 {
+		return 0, ErrMalformedPacket
+	}
+	return binary.BigEndian.Uint16(p.Payload), nil
+}
+
+func idPacket(t byte, flags byte, id uint16) *Packet {
+	return &Packet{Type: t, Flags: flags, Payload: binary.BigEndian.AppendUint16(nil, id)}
+}
+
+// Match reports whether topic matches filter with + and # wildcards
+func Match(filter string, topic string) bool {
+	fi, ti := 0, 0
+	for {
+		fEnd := indexOrLen(filter, fi)
+		tEnd := indexOrLen(topic, ti)
+		level := filter[fi:fEnd]
+		if level == "#" {
+			return true
+		}
+		if ti > len(topic) {
+			return false
+		}
+		if level != "+" && level != topic[ti:tEnd] {
+			return false
+		}
+		fi, ti = fEnd+1, tEnd+1
+		if fi > len(filter) {
+			return ti > len(topic)
+		}
+		if ti > len(topic) {
+			return filter[fi:] == "#"
+		}
+	}
+}
+
+func indexOrLen(s string, from int) int {
+	for i := from; i < len(s); i++ {
+		if s[i] == '/' {
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,78 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestRemainingLength(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 16383, 16384, 2097151, 2097152, maxRemainingLength} {
+		buf := appendRemainingLength(nil, n)
+		got, err := readRemainingLength(bufio.NewReader(bytes.NewReader(buf)))
+		if err != nil || got != n {
+			t.Fatalf("%d encoded as % x decodes to %d, %v", n, buf, got, err)
+		}
+	}
+	if _, err := readRemainingLength(bufio.NewReader(bytes.NewReader([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0x01}))); err != ErrMalformedPacket {
+		t.Fatalf("5 byte length: error %v", err)
+	}
+}
+
+func TestReadPacketTooLarge(t *testing.T) {
+	header := appendRemainingLength([]byte{PUBLISH << 4}, maxReadLength+1)
+	if _, err := ReadPacket(bufio.NewReader(bytes.NewReader(header))); err != ErrPacketTooLarge {
+		t.Fatalf("length %d: error %v, want ErrPacketTooLarge", maxReadLength+1, err)
+	}
+	header = appendRemainingLength([]byte{PUBLISH << 4}, maxRemainingLength)
+	if _, err := ReadPacket(bufio.NewReader(bytes.NewReader(header))); err != ErrPacketTooLarge {
+		t.Fatalf("length %d: error %v, want ErrPacketTooLarge", maxRemainingLength, err)
+	}
+
+	buf := append(appendRemainingLength([]byte{PUBLISH << 4}, maxReadLength), make([]byte, maxReadLength)...)
+	p, err := ReadPacket(bufio.NewReader(bytes.NewReader(buf)))
+	if err != nil || len(p.Payload) != maxReadLength {
+		t.Fatalf("length %d: error %v", maxReadLength, err)
+	}
+}
+
+func TestPublishRoundTrip(t *testing.T) {
+	in := &Message{Topic: "blynk/V1", Payload: []byte("255"), QoS: 1, Retain: true, PacketID: 42}
+	var buf bytes.Buffer
+	if err := WritePacket(&buf, EncodePublish(in)); err != nil {
+		t.Fatal(err)
+	}
+	p, err := ReadPacket(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := DecodePublish(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Topic != in.Topic || !bytes.Equal(out.Payload, in.Payload) || out.QoS != 1 || !out.Retain || out.PacketID != 42 {
+		t.Fatalf("decoded %+v, want %+v", out, in)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"blynk/V1", "blynk/V1", true},
+		{"blynk/V1", "blynk/V2", false},
+		{"blynk/+/set", "blynk/V1/set", true},
+		{"blynk/+/set", "blynk/V1/state", false},
+		{"blynk/+", "blynk/V1/set", false},
+		{"blynk/#", "blynk/V1/set", true},
+		{"blynk/#", "blynk", true},
+		{"#", "blynk/V1", true},
+		{"blynk/V1/set", "blynk/V1", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
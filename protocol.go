@@ -5,7 +5,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"strconv"
-	"strings"
+
+	protocol "github.com/OloloevReal/go-blynk/protocol"
 )
 
 type BlynkMessage struct {
@@ -19,7 +20,12 @@ type BlynkHead struct {
 	Length    uint16
 }
 
-type BlynkBody strings.Builder
+// BlynkBody holds NUL separated fields of the message
+type BlynkBody struct {
+	buf    []byte
+	fields int
+	err    error
+}
 
 type BlynkRespose struct {
 	Command   BlynkCommand
@@ -28,65 +34,67 @@ type BlynkRespose struct {
 	Values    []string
 }
 
-type BlynkCommand byte
+type BlynkCommand = protocol.Command
+
+const (
+	BLYNK_HEAD_SIZE = protocol.HEAD_SIZE
+	BLYNK_MAX_BODY  = protocol.MAX_BODY
+)
+
+var (
+	ErrFieldContainsNUL = protocol.ErrFieldContainsNUL
+	ErrBodyTooLarge     = protocol.ErrBodyTooLarge
+)
 
 const (
-	BLYNK_CMD_RESPONSE      BlynkCommand = 0
-	BLYNK_CMD_LOGIN         BlynkCommand = 2
-	BLYNK_CMD_PING          BlynkCommand = 6
-	BLYNK_CMD_TWEET         BlynkCommand = 12
-	BLYNK_CMD_EMAIL         BlynkCommand = 13
-	BLYNK_CMD_NOTIFY        BlynkCommand = 14
-	BLYNK_CMD_HARDWARE_SYNC BlynkCommand = 16
-	BLYNK_CMD_INTERNAL      BlynkCommand = 17
-	BLYNK_CMD_HARDWARE      BlynkCommand = 20
-	BLYNK_CMD_HW_LOGIN      BlynkCommand = 29
+	BLYNK_CMD_RESPONSE      = protocol.CMD_RESPONSE
+	BLYNK_CMD_LOGIN         = protocol.CMD_LOGIN
+	BLYNK_CMD_PING          = protocol.CMD_PING
+	BLYNK_CMD_TWEET         = protocol.CMD_TWEET
+	BLYNK_CMD_EMAIL         = protocol.CMD_EMAIL
+	BLYNK_CMD_NOTIFY        = protocol.CMD_NOTIFY
+	BLYNK_CMD_HARDWARE_SYNC = protocol.CMD_HARDWARE_SYNC
+	BLYNK_CMD_INTERNAL      = protocol.CMD_INTERNAL
+	BLYNK_CMD_HARDWARE      = protocol.CMD_HARDWARE
+	BLYNK_CMD_HW_LOGIN      = protocol.CMD_HW_LOGIN
 )
 
 const (
-	BLYNK_SUCCESS             uint16 = 200
-	BLYNK_ILLEGAL_COMMAND     uint16 = 2
-	BLYNK_NOT_REGISTERED      uint16 = 3
-	BLYNK_NOT_AUTHENTICATED   uint16 = 5
-	BLYNK_NOT_ALLOWED         uint16 = 6
-	BLYNK_NO_ACTIVE_DASHBOARD uint16 = 8
-	BLYNK_INVALID_TOKEN       uint16 = 9
-	BLYNK_NTF_INVALID_BODY    uint16 = 13
-	BLYNK_NTF_NOT_AUTHORIZED  uint16 = 14
-	BLYNK_NTF_EXCEPTION       uint16 = 15
+	BLYNK_SUCCESS                       = protocol.STATUS_SUCCESS
+	BLYNK_QUOTA_LIMIT                   = protocol.STATUS_QUOTA_LIMIT
+	BLYNK_ILLEGAL_COMMAND               = protocol.STATUS_ILLEGAL_COMMAND
+	BLYNK_NOT_REGISTERED                = protocol.STATUS_NOT_REGISTERED
+	BLYNK_USER_ALREADY_REGISTERED       = protocol.STATUS_USER_ALREADY_REGISTERED
+	BLYNK_NOT_AUTHENTICATED             = protocol.STATUS_NOT_AUTHENTICATED
+	BLYNK_NOT_ALLOWED                   = protocol.STATUS_NOT_ALLOWED
+	BLYNK_DEVICE_NOT_IN_NETWORK         = protocol.STATUS_DEVICE_NOT_IN_NETWORK
+	BLYNK_NO_ACTIVE_DASHBOARD           = protocol.STATUS_NO_ACTIVE_DASHBOARD
+	BLYNK_INVALID_TOKEN                 = protocol.STATUS_INVALID_TOKEN
+	BLYNK_ILLEGAL_COMMAND_BODY          = protocol.STATUS_ILLEGAL_COMMAND_BODY
+	BLYNK_GET_GRAPH_DATA_EXCEPTION      = protocol.STATUS_GET_GRAPH_DATA_EXCEPTION
+	BLYNK_NTF_INVALID_BODY              = protocol.STATUS_NTF_INVALID_BODY
+	BLYNK_NTF_NOT_AUTHORIZED            = protocol.STATUS_NTF_NOT_AUTHORIZED
+	BLYNK_NTF_EXCEPTION                 = protocol.STATUS_NTF_EXCEPTION
+	BLYNK_TIMEOUT                       = protocol.STATUS_TIMEOUT
+	BLYNK_NO_DATA                       = protocol.STATUS_NO_DATA
+	BLYNK_DEVICE_WENT_OFFLINE           = protocol.STATUS_DEVICE_WENT_OFFLINE
+	BLYNK_SERVER_EXCEPTION              = protocol.STATUS_SERVER_EXCEPTION
+	BLYNK_NOT_SUPPORTED_VERSION         = protocol.STATUS_NOT_SUPPORTED_VERSION
+	BLYNK_ENERGY_LIMIT                  = protocol.STATUS_ENERGY_LIMIT
+	BLYNK_FACEBOOK_USER_LOGIN_WITH_PASS = protocol.STATUS_FACEBOOK_USER_LOGIN_WITH_PASS
 )
 
 func GetBlynkStatus(status uint16) string {
-	switch status {
-	case BLYNK_SUCCESS:
-		return "SUCCESS"
-	case BLYNK_ILLEGAL_COMMAND:
-		return "ILLEGAL_COMMAND"
-	case BLYNK_NOT_REGISTERED:
-		return "NOT_REGISTERED"
-	case BLYNK_NOT_AUTHENTICATED:
-		return "NOT_AUTHENTICATED"
-	case BLYNK_NOT_ALLOWED:
-		return "NOT_ALLOWED"
-	case BLYNK_NO_ACTIVE_DASHBOARD:
-		return "NO_ACTIVE_DASHBOARD"
-	case BLYNK_INVALID_TOKEN:
-		return "INVALID_TOKEN"
-	case BLYNK_NTF_INVALID_BODY:
-		return "NTF_INVALID_BODY"
-	case BLYNK_NTF_NOT_AUTHORIZED:
-		return "NTF_NOT_AUTHORIZED"
-	case BLYNK_NTF_EXCEPTION:
-		return "NTF_EXCEPTION"
-	default:
-		return "UNDEFINED"
-	}
+	return protocol.StatusText(status)
 }
 
 func (b *BlynkMessage) GetBytes() []byte {
 	if b == nil {
 		return nil
 	}
+	if _, err := b.Body.Length(); err != nil {
+		return nil
+	}
 	var writer bytes.Buffer
 
 	bts, err := b.Head.getBytes()
@@ -102,6 +110,34 @@ func (b *BlynkMessage) GetBytes() []byte {
 	return writer.Bytes()
 }
 
+// MarshalBinary is strict version of GetBytes, it fails on invalid body fields and on body length mismatch
+func (b *BlynkMessage) MarshalBinary() ([]byte, error) {
+	if b == nil {
+		return nil, fmt.Errorf("BlynkMessage is nil")
+	}
+	if err := b.Body.Err(); err != nil {
+		return nil, err
+	}
+	length, err := b.Body.Length()
+	if err != nil {
+		return nil, err
+	}
+	if b.Head.Command != BLYNK_CMD_RESPONSE && b.Head.Length != length {
+		return nil, fmt.Errorf("BlynkMessage: head length %d doesn't match body length %d", b.Head.Length, length)
+	}
+
+	return protocol.AppendFrame(nil, b.frame())
+}
+
+func (b *BlynkMessage) frame() *protocol.Frame {
+	return &protocol.Frame{
+		Command:   b.Head.Command,
+		MessageId: b.Head.MessageId,
+		Length:    b.Head.Length,
+		Body:      b.Body.buf,
+	}
+}
+
 func (b *BlynkHead) getBytes() ([]byte, error) {
 	if b == nil {
 		return nil, fmt.Errorf("BlynkHead is nil")
@@ -116,56 +152,95 @@ func (b *BlynkHead) getBytes() ([]byte, error) {
 	return writer.Bytes(), nil
 }
 
+func AppendFields(dst []byte, fields ...[]byte) ([]byte, error) {
+	return protocol.AppendFields(dst, fields...)
+}
+
+func SplitFields(buf []byte) [][]byte {
+	return protocol.SplitFields(buf)
+}
+
 func (b *BlynkBody) String() string {
 	if b == nil {
 		return ""
 	}
+	return string(b.buf)
+}
 
-	builder := (*strings.Builder)(b)
-	return builder.String()
+func (b *BlynkBody) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	return b.buf
+}
+
+// Err returns the first error of Add* calls
+func (b *BlynkBody) Err() error {
+	if b == nil {
+		return fmt.Errorf("BlynkBody is nil")
+	}
+	return b.err
 }
 
 func (b *BlynkBody) Clear() {
 	if b == nil {
 		return
 	}
-	builder := (*strings.Builder)(b)
-	builder.Reset()
+	b.buf = b.buf[:0]
+	b.fields = 0
+	b.err = nil
+}
+
+func (b *BlynkBody) add(field []byte) error {
+	if bytes.IndexByte(field, 0x00) >= 0 {
+		if b.err == nil {
+			b.err = ErrFieldContainsNUL
+		}
+		return ErrFieldContainsNUL
+	}
+	if b.fields != 0 {
+		b.buf = append(b.buf, 0x00)
+	}
+	b.buf = append(b.buf, field...)
+	b.fields++
+	return nil
 }
 
-func (b *BlynkBody) AddString(s string) {
+func (b *BlynkBody) AddString(values ...string) error {
 	if b == nil {
-		return
+		return fmt.Errorf("BlynkBody is nil")
 	}
-	builder := (*strings.Builder)(b)
-	if builder.Len() != 0 {
-		builder.WriteByte(0x00)
+	var err error
+	for _, v := range values {
+		if e := b.add([]byte(v)); e != nil && err == nil {
+			err = e
+		}
 	}
-	builder.Write([]byte(s))
+	return err
+}
 
+func (b *BlynkBody) AddBytes(buf []byte) error {
+	if b == nil {
+		return fmt.Errorf("BlynkBody is nil")
+	}
+	return b.add(buf)
 }
 
-func (b *BlynkBody) AddBytes(buf []byte) {
+func (b *BlynkBody) AddInt(values ...int) {
 	if b == nil {
 		return
 	}
-	builder := (*strings.Builder)(b)
-	if builder.Len() != 0 {
-		builder.WriteByte(0x00)
+	for _, v := range values {
+		b.add(strconv.AppendInt(nil, int64(v), 10))
 	}
-	builder.Write(buf)
 }
 
-func (b *BlynkBody) AddInt(values ...int) {
+func (b *BlynkBody) AddFloat(values ...float64) {
 	if b == nil {
 		return
 	}
-	builder := (*strings.Builder)(b)
 	for _, v := range values {
-		if builder.Len() != 0 {
-			builder.WriteByte(0x00)
-		}
-		builder.Write([]byte(strconv.Itoa(v)))
+		b.add(strconv.AppendFloat(nil, v, 'f', -1, 64))
 	}
 }
 
@@ -173,44 +248,45 @@ func (b *BlynkBody) AddBool(v bool) {
 	if b == nil {
 		return
 	}
-
-	builder := (*strings.Builder)(b)
-	if builder.Len() != 0 {
-		builder.WriteByte(0x00)
-	}
 	if v {
-		builder.WriteByte(0x31)
+		b.add([]byte{0x31})
 	} else {
-		builder.WriteByte(0x30)
+		b.add([]byte{0x30})
 	}
 }
 
+// Len returns body length for the head, an oversize body returns 0 and is kept in Err,
+// so MarshalBinary fails instead of writing a truncated length
 func (b *BlynkBody) Len() uint16 {
+	length, err := b.Length()
+	if err == ErrBodyTooLarge && b.err == nil {
+		b.err = err
+	}
+	return length
+}
+
+func (b *BlynkBody) Length() (uint16, error) {
 	if b == nil {
-		return 0
+		return 0, fmt.Errorf("BlynkBody is nil")
 	}
-	builder := (*strings.Builder)(b)
-	return uint16(builder.Len())
+	if len(b.buf) > BLYNK_MAX_BODY {
+		return 0, ErrBodyTooLarge
+	}
+	return uint16(len(b.buf)), nil
 }
 
 func (b *BlynkBody) getBytes() ([]byte, error) {
 	if b == nil {
 		return nil, fmt.Errorf("BlynkBody is nil")
 	}
-	builder := (*strings.Builder)(b)
-	return []byte(builder.String()), nil
-}
-
-func (r *BlynkRespose) parseHead(buf []byte) {
-	r.Command = BlynkCommand(buf[0])
-	r.MessageId = binary.BigEndian.Uint16(buf[1:3])
-	r.Status = binary.BigEndian.Uint16(buf[3:5])
-	r.Values = nil
+	return b.buf, nil
 }
 
-func (r *BlynkRespose) parseBody(buf []byte) {
-	bs := bytes.Split(buf, []byte{0x00})
-	for _, s := range bs {
-		r.Values = append(r.Values, string(s))
+func newBlynkRespose(f *protocol.Frame) *BlynkRespose {
+	return &BlynkRespose{
+		Command:   f.Command,
+		MessageId: f.MessageId,
+		Status:    f.Length,
+		Values:    f.Values(),
 	}
 }
@@ -0,0 +1,190 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrIncompleteFrame  = errors.New("blynk: incomplete frame")
+	ErrFieldContainsNUL = errors.New("blynk: body field contains NUL byte")
+	ErrBodyTooLarge     = errors.New("blynk: body exceeds 65535 bytes")
+)
+
+// FrameError describes a frame which is complete but can't be processed
+type FrameError struct {
+	Command   Command
+	MessageId uint16
+	Reason    string
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("blynk: malformed frame, command %d, message id %d: %s", e.Command, e.MessageId, e.Reason)
+}
+
+// Frame is a message of the protocol, Length is status code for responses and body length for other commands
+type Frame struct {
+	Command   Command
+	MessageId uint16
+	Length    uint16
+	Body      []byte
+}
+
+func (f *Frame) Fields() [][]byte {
+	return SplitFields(f.Body)
+}
+
+func (f *Frame) Values() []string {
+	var values []string
+	for _, v := range f.Fields() {
+		values = append(values, string(v))
+	}
+	return values
+}
+
+// NewFrame builds a frame with NUL separated values as body
+func NewFrame(cmd Command, id uint16, values ...string) (*Frame, error) {
+	fields := make([][]byte, len(values))
+	for i, v := range values {
+		fields[i] = []byte(v)
+	}
+	body, err := AppendFields(nil, fields...)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Command: cmd, MessageId: id, Length: uint16(len(body)), Body: body}, nil
+}
+
+// AppendFields appends NUL separated fields to dst, fields must not contain NUL
+func AppendFields(dst []byte, fields ...[]byte) ([]byte, error) {
+	start := len(dst)
+	for i, f := range fields {
+		if bytes.IndexByte(f, 0x00) >= 0 {
+			return dst, ErrFieldContainsNUL
+		}
+		if i > 0 {
+			dst = append(dst, 0x00)
+		}
+		dst = append(dst, f...)
+	}
+	if len(dst)-start > MAX_BODY {
+		return dst, ErrBodyTooLarge
+	}
+	return dst, nil
+}
+
+// SplitFields splits body to NUL separated fields, the fields share memory with buf
+func SplitFields(buf []byte) [][]byte {
+	if len(buf) == 0 {
+		return nil
+	}
+	return bytes.Split(buf, []byte{0x00})
+}
+
+// AppendFrame appends encoded frame to dst
+func AppendFrame(dst []byte, f *Frame) ([]byte, error) {
+	if f == nil {
+		return dst, fmt.Errorf("blynk: frame is nil")
+	}
+	if len(f.Body) > MAX_BODY {
+		return dst, ErrBodyTooLarge
+	}
+	if f.Command == CMD_RESPONSE && len(f.Body) != 0 {
+		return dst, &FrameError{Command: f.Command, MessageId: f.MessageId, Reason: "response can't have body"}
+	}
+	if f.Command != CMD_RESPONSE && int(f.Length) != len(f.Body) {
+		return dst, &FrameError{Command: f.Command, MessageId: f.MessageId, Reason: fmt.Sprintf("length %d doesn't match body length %d", f.Length, len(f.Body))}
+	}
+
+	dst = append(dst, byte(f.Command))
+	dst = binary.BigEndian.AppendUint16(dst, f.MessageId)
+	dst = binary.BigEndian.AppendUint16(dst, f.Length)
+	return append(dst, f.Body...), nil
+}
+
+// DecodeFrame decodes the first frame of buf and returns the number of consumed bytes.
+// ErrIncompleteFrame is returned when buf holds only a part of the frame, Body shares memory with buf.
+func DecodeFrame(buf []byte) (*Frame, int, error) {
+	if len(buf) < HEAD_SIZE {
+		return nil, 0, ErrIncompleteFrame
+	}
+
+	f := &Frame{
+		Command:   Command(buf[0]),
+		MessageId: binary.BigEndian.Uint16(buf[1:3]),
+		Length:    binary.BigEndian.Uint16(buf[3:5]),
+	}
+
+	//response carries status code instead of body length
+	if f.Command == CMD_RESPONSE {
+		return f, HEAD_SIZE, nil
+	}
+
+	end := HEAD_SIZE + int(f.Length)
+	if len(buf) < end {
+		return nil, 0, ErrIncompleteFrame
+	}
+	f.Body = buf[HEAD_SIZE:end]
+	return f, end, nil
+}
+
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the frame with a single Write call
+func (e *Encoder) Encode(f *Frame) error {
+	buf, err := AppendFrame(e.buf[:0], f)
+	if err != nil {
+		return err
+	}
+	e.buf = buf
+	_, err = e.w.Write(buf)
+	return err
+}
+
+type Decoder struct {
+	r    io.Reader
+	head [HEAD_SIZE]byte
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads the next frame, io.EOF is returned only when the stream ends on the frame boundary
+func (d *Decoder) Decode() (*Frame, error) {
+	if _, err := io.ReadFull(d.r, d.head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, ErrIncompleteFrame
+		}
+		return nil, err
+	}
+
+	f, _, err := DecodeFrame(d.head[:])
+	if err == nil {
+		return f, nil
+	}
+
+	f = &Frame{
+		Command:   Command(d.head[0]),
+		MessageId: binary.BigEndian.Uint16(d.head[1:3]),
+		Length:    binary.BigEndian.Uint16(d.head[3:5]),
+		Body:      make([]byte, binary.BigEndian.Uint16(d.head[3:5])),
+	}
+	if _, err := io.ReadFull(d.r, f.Body); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrIncompleteFrame
+		}
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,159 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	hw, err := NewFrame(CMD_HARDWARE, 7, "vw", "1", "255")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := []*Frame{
+		hw,
+		{Command: CMD_RESPONSE, MessageId: 8, Length: STATUS_SUCCESS},
+		{Command: CMD_PING, MessageId: 0xFFFF},
+	}
+
+	var stream bytes.Buffer
+	enc := NewEncoder(&stream)
+	for _, f := range frames {
+		if err := enc.Encode(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := stream.Bytes()[:HEAD_SIZE], []byte{0x14, 0x00, 0x07, 0x00, 0x08}; !bytes.Equal(got, want) {
+		t.Fatalf("head %x, want %x", got, want)
+	}
+
+	dec := NewDecoder(&stream)
+	for _, want := range frames {
+		got, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sameFrame(got, want) {
+			t.Fatalf("decoded %+v, want %+v", got, want)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Fatalf("error %v at the end of stream, want io.EOF", err)
+	}
+}
+
+// TestFrameRoundTripRandom checks that any sequence of frames decodes to itself from one buffer
+func TestFrameRoundTripRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	commands := []Command{CMD_RESPONSE, CMD_PING, CMD_HARDWARE, CMD_INTERNAL, Command(200)}
+
+	for i := 0; i < 200; i++ {
+		var frames []*Frame
+		var buf []byte
+		for n := rnd.Intn(5); n >= 0; n-- {
+			f := &Frame{Command: commands[rnd.Intn(len(commands))], MessageId: uint16(rnd.Intn(0x10000))}
+			if f.Command == CMD_RESPONSE {
+				f.Length = uint16(rnd.Intn(20))
+			} else {
+				f.Body = make([]byte, rnd.Intn(64))
+				rnd.Read(f.Body)
+				f.Length = uint16(len(f.Body))
+			}
+			var err error
+			if buf, err = AppendFrame(buf, f); err != nil {
+				t.Fatal(err)
+			}
+			frames = append(frames, f)
+		}
+
+		rest := buf
+		for _, want := range frames {
+			got, n, err := DecodeFrame(rest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameFrame(got, want) {
+				t.Fatalf("decoded %+v, want %+v", got, want)
+			}
+			rest = rest[n:]
+		}
+		if len(rest) != 0 {
+			t.Fatalf("%d bytes left", len(rest))
+		}
+	}
+}
+
+func TestDecodeFrameIncomplete(t *testing.T) {
+	buf, err := AppendFrame(nil, &Frame{Command: CMD_HARDWARE, MessageId: 1, Length: 3, Body: []byte("abc")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(buf); i++ {
+		if _, _, err := DecodeFrame(buf[:i]); !errors.Is(err, ErrIncompleteFrame) {
+			t.Fatalf("%d bytes: error %v, want ErrIncompleteFrame", i, err)
+		}
+	}
+	if _, err := NewDecoder(bytes.NewReader(buf[:len(buf)-1])).Decode(); !errors.Is(err, ErrIncompleteFrame) {
+		t.Fatalf("decoder error %v, want ErrIncompleteFrame", err)
+	}
+}
+
+func TestAppendFrameErrors(t *testing.T) {
+	var fe *FrameError
+	if _, err := AppendFrame(nil, &Frame{Command: CMD_RESPONSE, Body: []byte("x")}); !errors.As(err, &fe) {
+		t.Fatalf("response with body: error %v", err)
+	}
+	if _, err := AppendFrame(nil, &Frame{Command: CMD_HARDWARE, Length: 2, Body: []byte("x")}); !errors.As(err, &fe) {
+		t.Fatalf("length mismatch: error %v", err)
+	}
+	if _, err := AppendFrame(nil, &Frame{Command: CMD_HARDWARE, Body: make([]byte, MAX_BODY+1)}); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("oversize body: error %v", err)
+	}
+}
+
+func FuzzDecodeFrame(f *testing.F) {
+	f.Add([]byte{0x14, 0x00, 0x01, 0x00, 0x08, 'v', 'w', 0x00, '1', 0x00, '2', '5', '5'})
+	f.Add([]byte{0x00, 0x00, 0x01, 0x00, 0xC8})
+	f.Add([]byte{0x06, 0x00, 0x01, 0xFF, 0xFF})
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		frame, n, err := DecodeFrame(data)
+		if err != nil {
+			if !errors.Is(err, ErrIncompleteFrame) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if _, err := NewDecoder(bytes.NewReader(data)).Decode(); err == nil {
+				t.Fatal("decoder accepts incomplete frame")
+			}
+			return
+		}
+		if n < HEAD_SIZE || n > len(data) {
+			t.Fatalf("consumed %d of %d bytes", n, len(data))
+		}
+
+		buf, err := AppendFrame(nil, frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, data[:n]) {
+			t.Fatalf("encoded %x, want %x", buf, data[:n])
+		}
+
+		streamed, err := NewDecoder(bytes.NewReader(data)).Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sameFrame(streamed, frame) {
+			t.Fatalf("decoder %+v, DecodeFrame %+v", streamed, frame)
+		}
+	})
+}
+
+func sameFrame(a, b *Frame) bool {
+	return a.Command == b.Command && a.MessageId == b.MessageId && a.Length == b.Length &&
+		bytes.Equal(a.Body, b.Body) && reflect.DeepEqual(a.Values(), b.Values())
+}
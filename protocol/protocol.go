@@ -0,0 +1,153 @@
+package protocol
+
+import "fmt"
+
+type Command byte
+
+const (
+	CMD_RESPONSE                Command = 0
+	CMD_REGISTER                Command = 1
+	CMD_LOGIN                   Command = 2
+	CMD_SAVE_PROF               Command = 3
+	CMD_LOAD_PROF               Command = 4
+	CMD_GET_TOKEN               Command = 5
+	CMD_PING                    Command = 6
+	CMD_ACTIVATE                Command = 7
+	CMD_DEACTIVATE              Command = 8
+	CMD_REFRESH                 Command = 9
+	CMD_GET_GRAPH_DATA          Command = 10
+	CMD_GET_GRAPH_DATA_RESPONSE Command = 11
+	CMD_TWEET                   Command = 12
+	CMD_EMAIL                   Command = 13
+	CMD_NOTIFY                  Command = 14
+	CMD_BRIDGE                  Command = 15
+	CMD_HARDWARE_SYNC           Command = 16
+	CMD_INTERNAL                Command = 17
+	CMD_SMS                     Command = 18
+	CMD_PROPERTY                Command = 19
+	CMD_HARDWARE                Command = 20
+	CMD_CREATE_DASH             Command = 21
+	CMD_SAVE_DASH               Command = 22
+	CMD_DELETE_DASH             Command = 23
+	CMD_LOAD_PROF_GZ            Command = 24
+	CMD_SYNC                    Command = 25
+	CMD_SHARING                 Command = 26
+	CMD_ADD_PUSH_TOKEN          Command = 27
+	CMD_HW_LOGIN                Command = 29
+	CMD_GET_SHARE_TOKEN         Command = 30
+	CMD_REFRESH_SHARE_TOKEN     Command = 31
+	CMD_SHARE_LOGIN             Command = 32
+	CMD_GET_SERVER              Command = 40
+	CMD_REDIRECT                Command = 41
+	CMD_DEBUG_PRINT             Command = 55
+	CMD_EVENT_LOG               Command = 64
+)
+
+var commandText = map[Command]string{
+	CMD_RESPONSE:                "RESPONSE",
+	CMD_REGISTER:                "REGISTER",
+	CMD_LOGIN:                   "LOGIN",
+	CMD_SAVE_PROF:               "SAVE_PROF",
+	CMD_LOAD_PROF:               "LOAD_PROF",
+	CMD_GET_TOKEN:               "GET_TOKEN",
+	CMD_PING:                    "PING",
+	CMD_ACTIVATE:                "ACTIVATE",
+	CMD_DEACTIVATE:              "DEACTIVATE",
+	CMD_REFRESH:                 "REFRESH",
+	CMD_GET_GRAPH_DATA:          "GET_GRAPH_DATA",
+	CMD_GET_GRAPH_DATA_RESPONSE: "GET_GRAPH_DATA_RESPONSE",
+	CMD_TWEET:                   "TWEET",
+	CMD_EMAIL:                   "EMAIL",
+	CMD_NOTIFY:                  "NOTIFY",
+	CMD_BRIDGE:                  "BRIDGE",
+	CMD_HARDWARE_SYNC:           "HARDWARE_SYNC",
+	CMD_INTERNAL:                "INTERNAL",
+	CMD_SMS:                     "SMS",
+	CMD_PROPERTY:                "PROPERTY",
+	CMD_HARDWARE:                "HARDWARE",
+	CMD_CREATE_DASH:             "CREATE_DASH",
+	CMD_SAVE_DASH:               "SAVE_DASH",
+	CMD_DELETE_DASH:             "DELETE_DASH",
+	CMD_LOAD_PROF_GZ:            "LOAD_PROF_GZ",
+	CMD_SYNC:                    "SYNC",
+	CMD_SHARING:                 "SHARING",
+	CMD_ADD_PUSH_TOKEN:          "ADD_PUSH_TOKEN",
+	CMD_HW_LOGIN:                "HW_LOGIN",
+	CMD_GET_SHARE_TOKEN:         "GET_SHARE_TOKEN",
+	CMD_REFRESH_SHARE_TOKEN:     "REFRESH_SHARE_TOKEN",
+	CMD_SHARE_LOGIN:             "SHARE_LOGIN",
+	CMD_GET_SERVER:              "GET_SERVER",
+	CMD_REDIRECT:                "REDIRECT",
+	CMD_DEBUG_PRINT:             "DEBUG_PRINT",
+	CMD_EVENT_LOG:               "EVENT_LOG",
+}
+
+const (
+	STATUS_SUCCESS                       uint16 = 200
+	STATUS_QUOTA_LIMIT                   uint16 = 1
+	STATUS_ILLEGAL_COMMAND               uint16 = 2
+	STATUS_NOT_REGISTERED                uint16 = 3
+	STATUS_USER_ALREADY_REGISTERED       uint16 = 4
+	STATUS_NOT_AUTHENTICATED             uint16 = 5
+	STATUS_NOT_ALLOWED                   uint16 = 6
+	STATUS_DEVICE_NOT_IN_NETWORK         uint16 = 7
+	STATUS_NO_ACTIVE_DASHBOARD           uint16 = 8
+	STATUS_INVALID_TOKEN                 uint16 = 9
+	STATUS_ILLEGAL_COMMAND_BODY          uint16 = 11
+	STATUS_GET_GRAPH_DATA_EXCEPTION      uint16 = 12
+	STATUS_NTF_INVALID_BODY              uint16 = 13
+	STATUS_NTF_NOT_AUTHORIZED            uint16 = 14
+	STATUS_NTF_EXCEPTION                 uint16 = 15
+	STATUS_TIMEOUT                       uint16 = 16
+	STATUS_NO_DATA                       uint16 = 17
+	STATUS_DEVICE_WENT_OFFLINE           uint16 = 18
+	STATUS_SERVER_EXCEPTION              uint16 = 19
+	STATUS_NOT_SUPPORTED_VERSION         uint16 = 20
+	STATUS_ENERGY_LIMIT                  uint16 = 21
+	STATUS_FACEBOOK_USER_LOGIN_WITH_PASS uint16 = 22
+)
+
+var statusText = map[uint16]string{
+	STATUS_SUCCESS:                       "SUCCESS",
+	STATUS_QUOTA_LIMIT:                   "QUOTA_LIMIT",
+	STATUS_ILLEGAL_COMMAND:               "ILLEGAL_COMMAND",
+	STATUS_NOT_REGISTERED:                "NOT_REGISTERED",
+	STATUS_USER_ALREADY_REGISTERED:       "USER_ALREADY_REGISTERED",
+	STATUS_NOT_AUTHENTICATED:             "NOT_AUTHENTICATED",
+	STATUS_NOT_ALLOWED:                   "NOT_ALLOWED",
+	STATUS_DEVICE_NOT_IN_NETWORK:         "DEVICE_NOT_IN_NETWORK",
+	STATUS_NO_ACTIVE_DASHBOARD:           "NO_ACTIVE_DASHBOARD",
+	STATUS_INVALID_TOKEN:                 "INVALID_TOKEN",
+	STATUS_ILLEGAL_COMMAND_BODY:          "ILLEGAL_COMMAND_BODY",
+	STATUS_GET_GRAPH_DATA_EXCEPTION:      "GET_GRAPH_DATA_EXCEPTION",
+	STATUS_NTF_INVALID_BODY:              "NTF_INVALID_BODY",
+	STATUS_NTF_NOT_AUTHORIZED:            "NTF_NOT_AUTHORIZED",
+	STATUS_NTF_EXCEPTION:                 "NTF_EXCEPTION",
+	STATUS_TIMEOUT:                       "TIMEOUT",
+	STATUS_NO_DATA:                       "NO_DATA",
+	STATUS_DEVICE_WENT_OFFLINE:           "DEVICE_WENT_OFFLINE",
+	STATUS_SERVER_EXCEPTION:              "SERVER_EXCEPTION",
+	STATUS_NOT_SUPPORTED_VERSION:         "NOT_SUPPORTED_VERSION",
+	STATUS_ENERGY_LIMIT:                  "ENERGY_LIMIT",
+	STATUS_FACEBOOK_USER_LOGIN_WITH_PASS: "FACEBOOK_USER_LOGIN_WITH_PASS",
+}
+
+const (
+	HEAD_SIZE = 5
+	MAX_BODY  = 0xFFFF
+)
+
+// String returns the name of the command, unknown commands are named by the code, e.g. CMD_99
+func (c Command) String() string {
+	if text, ok := commandText[c]; ok {
+		return text
+	}
+	return fmt.Sprintf("CMD_%d", c)
+}
+
+func StatusText(status uint16) string {
+	if text, ok := statusText[status]; ok {
+		return text
+	}
+	return "UNDEFINED"
+}
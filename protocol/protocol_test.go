@@ -0,0 +1,36 @@
+package protocol
+
+import "testing"
+
+func TestCommandString(t *testing.T) {
+	tests := []struct {
+		cmd  Command
+		want string
+	}{
+		{CMD_RESPONSE, "RESPONSE"},
+		{CMD_HARDWARE, "HARDWARE"},
+		{CMD_BRIDGE, "BRIDGE"},
+		{CMD_PROPERTY, "PROPERTY"},
+		{CMD_REDIRECT, "REDIRECT"},
+		{CMD_EVENT_LOG, "EVENT_LOG"},
+		{Command(28), "CMD_28"},
+		{Command(255), "CMD_255"},
+	}
+	for _, tt := range tests {
+		if got := tt.cmd.String(); got != tt.want {
+			t.Errorf("Command(%d).String() = %s, want %s", byte(tt.cmd), got, tt.want)
+		}
+	}
+}
+
+func TestStatusText(t *testing.T) {
+	if got := StatusText(STATUS_INVALID_TOKEN); got != "INVALID_TOKEN" {
+		t.Errorf("StatusText(9) = %s", got)
+	}
+	if got := StatusText(STATUS_SUCCESS); got != "SUCCESS" {
+		t.Errorf("StatusText(200) = %s", got)
+	}
+	if got := StatusText(10); got != "UNDEFINED" {
+		t.Errorf("StatusText(10) = %s", got)
+	}
+}
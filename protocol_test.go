@@ -0,0 +1,94 @@
+package blynk
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBodyOversize(t *testing.T) {
+	msg := BlynkMessage{}
+	msg.Head.Command = BLYNK_CMD_HARDWARE
+	msg.Body.AddString("vw", "1", strings.Repeat("x", BLYNK_MAX_BODY))
+	msg.Head.Length = msg.Body.Len()
+
+	if msg.Head.Length != 0 {
+		t.Fatalf("Len returned truncated length %d", msg.Head.Length)
+	}
+	if _, err := msg.Body.Length(); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("Length error %v, want ErrBodyTooLarge", err)
+	}
+	if _, err := msg.MarshalBinary(); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("MarshalBinary error %v, want ErrBodyTooLarge", err)
+	}
+	if buf := msg.GetBytes(); buf != nil {
+		t.Fatalf("GetBytes returned %d bytes of oversize message", len(buf))
+	}
+}
+
+func TestBodyNUL(t *testing.T) {
+	msg := BlynkMessage{}
+	msg.Head.Command = BLYNK_CMD_HARDWARE
+	if err := msg.Body.AddString("vw", "a\x00b"); !errors.Is(err, ErrFieldContainsNUL) {
+		t.Fatalf("AddString error %v, want ErrFieldContainsNUL", err)
+	}
+	msg.Head.Length = msg.Body.Len()
+	if _, err := msg.MarshalBinary(); !errors.Is(err, ErrFieldContainsNUL) {
+		t.Fatalf("MarshalBinary error %v, want ErrFieldContainsNUL", err)
+	}
+}
+
+func FuzzBodyFields(f *testing.F) {
+	f.Add("vw", "1", "255")
+	f.Add("", "", "")
+	f.Add("a\x00b", "x", "")
+	f.Fuzz(func(t *testing.T, a, b, c string) {
+		msg := BlynkMessage{}
+		msg.Head.Command = BLYNK_CMD_HARDWARE
+		msg.Head.MessageId = 1
+		err := msg.Body.AddString(a, b, c)
+		msg.Head.Length = msg.Body.Len()
+
+		hasNUL := strings.ContainsRune(a+b+c, 0)
+		if hasNUL {
+			if err == nil || msg.Body.Err() == nil {
+				t.Fatal("field with NUL is accepted")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf, err := msg.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, n, err := DecodeFrame(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(buf) {
+			t.Fatalf("consumed %d of %d bytes", n, len(buf))
+		}
+		if got, want := strings.Join(resp.Values, "\x00"), a+"\x00"+b+"\x00"+c; got != want {
+			t.Fatalf("values %q, want %q", got, want)
+		}
+	})
+}
+
+func FuzzSplitFields(f *testing.F) {
+	f.Add([]byte("vw\x001\x00255"))
+	f.Add([]byte("\x00\x00"))
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, body []byte) {
+		buf, err := AppendFields(nil, SplitFields(body)...)
+		if err != nil && len(body) <= BLYNK_MAX_BODY {
+			t.Fatal(err)
+		}
+		if err == nil && !bytes.Equal(buf, body) {
+			t.Fatalf("fields joined to %q, want %q", buf, body)
+		}
+	})
+}
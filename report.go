@@ -0,0 +1,78 @@
+package blynk
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// ReportPolicy filters VirtualWrite of the pin, the zero policy sends the value only when it has changed.
+// Numeric values are treated as changed when they moved more than Deadband or RelDeadband (fraction of the last sent value).
+// MaxInterval forces sending of the unchanged value when it was sent more than MaxInterval ago.
+type ReportPolicy struct {
+	Deadband    float64
+	RelDeadband float64
+	MaxInterval time.Duration
+}
+
+type pinReport struct {
+	policy ReportPolicy
+	sent   bool
+	value  string
+	sentAt time.Time
+}
+
+func (g *Blynk) SetReportPolicy(pin int, policy ReportPolicy) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.reports[pin] = &pinReport{policy: policy}
+}
+
+func (g *Blynk) DeleteReportPolicy(pin int) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	delete(g.reports, pin)
+}
+
+func (g *Blynk) shouldReport(pin int, value string) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	r, ok := g.reports[pin]
+	if !ok || !r.sent {
+		return true
+	}
+	if r.policy.MaxInterval > 0 && g.clock.Now().Sub(r.sentAt) >= r.policy.MaxInterval {
+		return true
+	}
+	return r.changed(value)
+}
+
+func (g *Blynk) reported(pin int, value string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if r, ok := g.reports[pin]; ok {
+		r.sent = true
+		r.value = value
+		r.sentAt = g.clock.Now()
+	}
+}
+
+func (r *pinReport) changed(value string) bool {
+	last, err1 := strconv.ParseFloat(r.value, 64)
+	cur, err2 := strconv.ParseFloat(value, 64)
+	if err1 != nil || err2 != nil {
+		return value != r.value
+	}
+
+	diff := math.Abs(cur - last)
+	if r.policy.Deadband == 0 && r.policy.RelDeadband == 0 {
+		return diff != 0
+	}
+	if r.policy.Deadband > 0 && diff > r.policy.Deadband {
+		return true
+	}
+	if r.policy.RelDeadband > 0 && diff > math.Abs(last)*r.policy.RelDeadband {
+		return true
+	}
+	return false
+}
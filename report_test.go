@@ -0,0 +1,113 @@
+package blynk
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReportPolicySkipsUnchanged(t *testing.T) {
+	g, conn := newRecordBlynk()
+	g.SetReportPolicy(5, ReportPolicy{})
+
+	g.VirtualWrite(5, "1")
+	g.VirtualWrite(5, "1")
+	if got := bytes.Count(conn.Take(), []byte("vw\x005\x001")); got != 1 {
+		t.Fatalf("sent %d writes of the same value, want 1", got)
+	}
+}
+
+func TestReaderIgnoresReportPolicy(t *testing.T) {
+	g, conn := newRecordBlynk()
+	g.SetReportPolicy(5, ReportPolicy{})
+	g.AddReaderHandler(5, func(pin uint, w io.Writer) {
+		io.WriteString(w, "1")
+	})
+
+	g.VirtualWrite(5, "1")
+	conn.Take()
+
+	read := &BlynkRespose{Command: BLYNK_CMD_HARDWARE, MessageId: 7, Values: []string{"vr", "5"}}
+	if err := g.handleHardware(read); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(conn.Take(), []byte("vw\x005\x001")) {
+		t.Fatal("reader answer is not sent")
+	}
+}
+
+func TestReportPolicyBands(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ReportPolicy
+		values []string
+		sent   []string
+	}{
+		{"absolute", ReportPolicy{Deadband: 0.5}, []string{"20", "20.3", "20.5", "20.6", "20.2", "20.0"}, []string{"20", "20.6", "20.0"}},
+		{"relative", ReportPolicy{RelDeadband: 0.1}, []string{"100", "109", "91", "111", "101", "99"}, []string{"100", "111", "99"}},
+		{"relative negative", ReportPolicy{RelDeadband: 0.1}, []string{"-100", "-95", "-89"}, []string{"-100", "-89"}},
+		{"either band", ReportPolicy{Deadband: 5, RelDeadband: 0.01}, []string{"1000", "1004", "1011"}, []string{"1000", "1011"}},
+		{"text", ReportPolicy{Deadband: 5}, []string{"on", "on", "off"}, []string{"on", "off"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, conn := newRecordBlynk()
+			g.SetReportPolicy(5, tt.policy)
+			for _, v := range tt.values {
+				if err := g.VirtualWrite(5, v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var want []string
+			for _, v := range tt.sent {
+				want = append(want, "vw\x005\x00"+v)
+			}
+			if got := frameBodies(conn.Take()); !reflect.DeepEqual(got, want) {
+				t.Fatalf("sent %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestReportPolicyMaxInterval(t *testing.T) {
+	g, conn := newRecordBlynk()
+	clock := newFakeClock()
+	g.SetClock(clock)
+	g.SetReportPolicy(5, ReportPolicy{Deadband: 1, MaxInterval: time.Minute})
+
+	g.VirtualWrite(5, "10")
+	clock.Advance(59 * time.Second)
+	g.VirtualWrite(5, "10.5")
+	clock.Advance(time.Second)
+	// the interval since the last sent value is over, the value inside the band is sent
+	g.VirtualWrite(5, "10.5")
+	clock.Advance(30 * time.Second)
+	g.VirtualWrite(5, "10")
+	clock.Advance(30 * time.Second)
+	g.VirtualWrite(5, "10")
+
+	want := []string{"vw\x005\x0010", "vw\x005\x0010.5", "vw\x005\x0010"}
+	if got := frameBodies(conn.Take()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sent %q, want %q", got, want)
+	}
+}
+
+func TestReportPolicyMultiValue(t *testing.T) {
+	g, conn := newRecordBlynk()
+	g.SetReportPolicy(5, ReportPolicy{Deadband: 100})
+	g.VirtualWrite(5, "1", "2")
+	g.VirtualWrite(5, "1", "2")
+	if got := len(frameBodies(conn.Take())); got != 2 {
+		t.Fatalf("sent %d multi value writes, want 2", got)
+	}
+
+	g.DeleteReportPolicy(5)
+	g.VirtualWrite(5, "1")
+	g.VirtualWrite(5, "1")
+	if got := len(frameBodies(conn.Take())); got != 2 {
+		t.Fatalf("sent %d writes without policy, want 2", got)
+	}
+}
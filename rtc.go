@@ -0,0 +1,205 @@
+package blynk
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type WeekdayMask uint8
+
+func (m WeekdayMask) Has(d time.Weekday) bool {
+	return m&(1<<uint(d)) != 0
+}
+
+// SetRTCSync enables periodic server time sync while Processing is running, zero disables it
+func (g *Blynk) SetRTCSync(interval time.Duration) {
+	g.rtcSync = interval
+}
+
+// SetRTCLocation sets the timezone of the RTC widget, the server sends its wall clock time in this zone.
+// Default is time.Local
+func (g *Blynk) SetRTCLocation(loc *time.Location) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.rtcLocation = loc
+}
+
+// Now returns local time corrected by the offset received from the server on the last RTC sync
+func (g *Blynk) Now() time.Time {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return time.Now().Add(g.rtcOffset).In(g.rtcLocation)
+}
+
+// RequestServerTime asks the server for current time, the server answers in the timezone of the RTC widget
+func (g *Blynk) RequestServerTime() (time.Time, error) {
+	msg := BlynkMessage{}
+	msg.Head.Command = BLYNK_CMD_INTERNAL
+	msg.Head.MessageId = g.getMessageID()
+	msg.Body.AddString("rtc", "sync")
+	id := msg.Head.MessageId
+	msg.Head.Length = msg.Body.Len()
+
+	//if receiver is using dont use standalone receive func, the processor passes the reply by message id
+	var reply chan time.Time
+	if g.processingUsing {
+		reply = make(chan time.Time, 1)
+		g.lock.Lock()
+		g.rtcWaiters[id] = reply
+		g.lock.Unlock()
+		defer func() {
+			g.lock.Lock()
+			delete(g.rtcWaiters, id)
+			g.lock.Unlock()
+		}()
+	}
+
+	if _, err := g.sendMessage(msg); err != nil {
+		return time.Time{}, fmt.Errorf("rtc: send failed, %s", err.Error())
+	}
+
+	if reply != nil {
+		select {
+		case t := <-reply:
+			return t, nil
+		case <-time.After(g.timeoutMAX):
+			return time.Time{}, fmt.Errorf("rtc: timeout")
+		}
+	}
+
+	deadline := time.Now().Add(g.timeoutMAX)
+	for time.Now().Before(deadline) {
+		buf, err := g.receive(time.Until(deadline))
+		if err != nil {
+			return time.Time{}, err
+		}
+		resps, _ := g.parseResponce(buf)
+		for _, resp := range resps {
+			if resp.Command != BLYNK_CMD_INTERNAL || resp.MessageId != id {
+				continue
+			}
+			if t, ok := g.parseRTC(resp); ok {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("rtc: timeout")
+}
+
+func (g *Blynk) handleInternal(resp *BlynkRespose) {
+	t, ok := g.parseRTC(resp)
+	if !ok {
+		g.log.Printf("[DEBUG] Processor received unhandled internal msg: %v", resp.Values)
+		return
+	}
+	g.lock.Lock()
+	reply, ok := g.rtcWaiters[resp.MessageId]
+	delete(g.rtcWaiters, resp.MessageId)
+	g.lock.Unlock()
+	if !ok {
+		// a late reply of a request which timed out
+		g.log.Printf("[DEBUG] rtc: no request waits for reply %d", resp.MessageId)
+		return
+	}
+	reply <- t
+}
+
+func (g *Blynk) parseRTC(resp *BlynkRespose) (time.Time, bool) {
+	if len(resp.Values) < 2 || resp.Values[0] != "rtc" {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(resp.Values[1], 10, 64)
+	if err != nil {
+		g.log.Printf("[ERROR] rtc: bad time value %q", resp.Values[1])
+		return time.Time{}, false
+	}
+	// the server sends seconds of the wall clock in the widget timezone, not UTC
+	u := time.Unix(sec, 0).UTC()
+	g.lock.Lock()
+	t := time.Date(u.Year(), u.Month(), u.Day(), u.Hour(), u.Minute(), u.Second(), 0, g.rtcLocation)
+	g.rtcOffset = time.Until(t)
+	g.lock.Unlock()
+	return t, true
+}
+
+func (g *Blynk) rtcSyncer() {
+	g.log.Printf("RTC: started")
+	defer g.log.Printf("RTC: finished")
+	t := time.NewTicker(g.rtcSync)
+	defer t.Stop()
+	g.sendRTCSync()
+	for {
+		select {
+		case <-t.C:
+			g.sendRTCSync()
+		case <-g.cancel:
+			g.log.Printf("[DEBUG] RTC: Stop received")
+			return
+		}
+	}
+}
+
+func (g *Blynk) sendRTCSync() {
+	if _, err := g.RequestServerTime(); err != nil {
+		g.log.Printf("[ERROR] RTC: sync failed, %s", err.Error())
+	}
+}
+
+func (ti TimeInput) Location() *time.Location {
+	if ti.Timezone != "" {
+		if loc, err := time.LoadLocation(ti.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.FixedZone(ti.Timezone, int(ti.TZOffset.Seconds()))
+}
+
+func (ti TimeInput) Weekdays() WeekdayMask {
+	var m WeekdayMask
+	for _, d := range ti.Days {
+		m |= 1 << uint(d)
+	}
+	return m
+}
+
+// StartAt returns start time on the day of t in the widget location
+func (ti TimeInput) StartAt(t time.Time) (time.Time, bool) {
+	if !ti.HasStart {
+		return time.Time{}, false
+	}
+	return ti.midnight(t).Add(ti.Start), true
+}
+
+// StopAt returns stop time on the day of t in the widget location
+func (ti TimeInput) StopAt(t time.Time) (time.Time, bool) {
+	if !ti.HasStop {
+		return time.Time{}, false
+	}
+	return ti.midnight(t).Add(ti.Stop), true
+}
+
+// IsActive reports whether t is between start and stop on one of the selected week days, empty days mean every day
+func (ti TimeInput) IsActive(t time.Time) bool {
+	t = t.In(ti.Location())
+	if len(ti.Days) > 0 && !ti.Weekdays().Has(t.Weekday()) {
+		return false
+	}
+	start, ok := ti.StartAt(t)
+	if !ok {
+		return false
+	}
+	stop, ok := ti.StopAt(t)
+	if !ok {
+		return !t.Before(start)
+	}
+	if stop.Before(start) {
+		return !t.Before(start) || t.Before(stop)
+	}
+	return !t.Before(start) && t.Before(stop)
+}
+
+func (ti TimeInput) midnight(t time.Time) time.Time {
+	t = t.In(ti.Location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,160 @@
+package blynk
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseRTCLocation(t *testing.T) {
+	g, _ := newRecordBlynk()
+	g.SetRTCLocation(time.FixedZone("UTC+3", 3*3600))
+
+	// 2020-01-02 10:00:00 on the wall clock of the widget
+	wall := time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC).Unix()
+	got, ok := g.parseRTC(&BlynkRespose{Command: BLYNK_CMD_INTERNAL, Values: []string{"rtc", strconv.FormatInt(wall, 10)}})
+	if !ok {
+		t.Fatal("parseRTC failed")
+	}
+	if got.Unix() != wall-3*3600 {
+		t.Fatalf("got %v, want 07:00 UTC", got.UTC())
+	}
+	if got.Hour() != 10 {
+		t.Fatalf("got hour %d in the widget zone, want 10", got.Hour())
+	}
+}
+
+// answerRTC answers n rtc requests sent to conn in reverse order, the reply to request id is wall time id hours after 2020-01-01
+func answerRTC(g *Blynk, conn *recordConn, n int) {
+	var sent []byte
+	var ids []uint16
+	for len(ids) < n {
+		time.Sleep(time.Millisecond)
+		sent = append(sent, conn.Take()...)
+		resps, consumed := decodeFrames(sent)
+		sent = sent[consumed:]
+		for _, resp := range resps {
+			ids = append(ids, resp.MessageId)
+		}
+	}
+	for i := len(ids) - 1; i >= 0; i-- {
+		g.handleInternal(&BlynkRespose{
+			Command:   BLYNK_CMD_INTERNAL,
+			MessageId: ids[i],
+			Values:    []string{"rtc", strconv.FormatInt(rtcWall(ids[i]).Unix(), 10)},
+		})
+	}
+}
+
+func rtcWall(id uint16) time.Time {
+	return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(id) * time.Hour)
+}
+
+func TestRequestServerTimeConcurrent(t *testing.T) {
+	g, conn := newRecordBlynk()
+	g.processingUsing = true
+	g.timeoutMAX = 2 * time.Second
+	g.SetRTCLocation(time.UTC)
+
+	// reply of an earlier request that timed out is dropped without blocking
+	g.handleInternal(&BlynkRespose{Command: BLYNK_CMD_INTERNAL, MessageId: 1000, Values: []string{"rtc", "1"}})
+
+	const N = 3
+	go answerRTC(g, conn, N)
+	type result struct {
+		t   time.Time
+		err error
+	}
+	results := make(chan result, N)
+	for i := 0; i < N; i++ {
+		go func() {
+			t, err := g.RequestServerTime()
+			results <- result{t, err}
+		}()
+	}
+	// every request gets the reply with its own id, so all times differ
+	seen := make(map[time.Time]bool)
+	for i := 0; i < N; i++ {
+		r := <-results
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if seen[r.t] || r.t.Before(rtcWall(1)) {
+			t.Fatalf("unexpected reply %v", r.t)
+		}
+		seen[r.t] = true
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if len(g.rtcWaiters) != 0 {
+		t.Fatalf("%d waiters are left", len(g.rtcWaiters))
+	}
+}
+
+func TestTimeInput(t *testing.T) {
+	kyiv := time.FixedZone("UTC+3", 3*3600)
+	ti := TimeInput{
+		Start: 22 * time.Hour, Stop: 6 * time.Hour, HasStart: true, HasStop: true,
+		TZOffset: 3 * time.Hour, Days: []time.Weekday{time.Friday, time.Saturday},
+	}
+
+	if _, off := time.Date(2020, 1, 3, 0, 0, 0, 0, ti.Location()).Zone(); off != 3*3600 {
+		t.Fatalf("offset %d, want fixed zone of TZOffset", off)
+	}
+	if m := ti.Weekdays(); !m.Has(time.Friday) || !m.Has(time.Saturday) || m.Has(time.Sunday) {
+		t.Fatalf("weekdays %08b", m)
+	}
+
+	// Friday 2020-01-03 in the widget zone
+	friday := time.Date(2020, 1, 3, 12, 0, 0, 0, kyiv)
+	start, ok := ti.StartAt(friday.UTC())
+	if !ok || !start.Equal(time.Date(2020, 1, 3, 22, 0, 0, 0, kyiv)) {
+		t.Fatalf("StartAt() = %v, %v", start, ok)
+	}
+	stop, ok := ti.StopAt(friday.UTC())
+	if !ok || !stop.Equal(time.Date(2020, 1, 3, 6, 0, 0, 0, kyiv)) {
+		t.Fatalf("StopAt() = %v, %v", stop, ok)
+	}
+
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{time.Date(2020, 1, 3, 12, 0, 0, 0, kyiv), false},
+		{time.Date(2020, 1, 3, 22, 0, 0, 0, kyiv), true},
+		{time.Date(2020, 1, 3, 19, 30, 0, 0, time.UTC), true},
+		{time.Date(2020, 1, 4, 5, 59, 0, 0, kyiv), true},
+		{time.Date(2020, 1, 4, 6, 0, 0, 0, kyiv), false},
+		{time.Date(2020, 1, 5, 23, 0, 0, 0, kyiv), false},
+		{time.Date(2020, 1, 3, 1, 0, 0, 0, kyiv), true},
+	}
+	for _, tt := range tests {
+		if got := ti.IsActive(tt.t); got != tt.want {
+			t.Errorf("IsActive(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestTimeInputNotSet(t *testing.T) {
+	ti := TimeInput{Timezone: "UTC"}
+	if ti.Location() != time.UTC {
+		t.Fatalf("location %v, want UTC", ti.Location())
+	}
+	if _, ok := ti.StartAt(time.Now()); ok {
+		t.Fatal("StartAt() of unset start")
+	}
+	if _, ok := ti.StopAt(time.Now()); ok {
+		t.Fatal("StopAt() of unset stop")
+	}
+	if ti.IsActive(time.Now()) {
+		t.Fatal("IsActive() without start")
+	}
+
+	ti = TimeInput{Start: time.Hour, HasStart: true, Timezone: "Nowhere/Unknown", TZOffset: -2 * time.Hour}
+	if _, off := time.Now().In(ti.Location()).Zone(); off != -2*3600 {
+		t.Fatalf("offset %d of unknown zone, want TZOffset", off)
+	}
+	if !ti.IsActive(time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC)) || ti.IsActive(time.Date(2020, 1, 1, 2, 30, 0, 0, time.UTC)) {
+		t.Fatal("start without stop is active until the end of the day")
+	}
+}
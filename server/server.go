@@ -0,0 +1,217 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/OloloevReal/go-blynk/websocket"
+	slog "github.com/OloloevReal/go-simple-log"
+)
+
+const (
+	SERVER_HEARTBEAT     = time.Second * 10
+	SERVER_LOGIN_TIMEOUT = time.Second * 10
+	SERVER_SAVE_INTERVAL = time.Second * 10
+)
+
+// Server is a minimal Blynk hardware server: it authorizes devices by the store tokens, keeps last pin values
+// and routes pin writes and reads between the devices of one dashboard
+type Server struct {
+	store        *Store
+	lock         sync.Mutex
+	listeners    map[net.Listener]bool
+	sessions     map[string]*session
+	dashboards   map[string]map[*session]bool
+	saveInterval time.Duration
+	saveOnce     sync.Once
+	cancel       chan struct{}
+	closed       bool
+	wg           sync.WaitGroup
+}
+
+func NewServer(store *Store) *Server {
+	return &Server{
+		store:        store,
+		listeners:    make(map[net.Listener]bool),
+		sessions:     make(map[string]*session),
+		dashboards:   make(map[string]map[*session]bool),
+		saveInterval: SERVER_SAVE_INTERVAL,
+		cancel:       make(chan struct{}),
+	}
+}
+
+// SetSaveInterval sets how often the changed store is written to the file, it must be called before Serve
+func (s *Server) SetSaveInterval(d time.Duration) {
+	s.saveInterval = d
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts devices on the listener until Close, it can be called for several listeners (TCP and TLS)
+func (s *Server) Serve(ln net.Listener) error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		ln.Close()
+		return fmt.Errorf("server: closed")
+	}
+	s.listeners[ln] = true
+	s.lock.Unlock()
+	s.saveOnce.Do(func() {
+		s.wg.Add(1)
+		go s.saver()
+	})
+
+	slog.Printf("[INFO] server: listening on %s", ln.Addr())
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.lock.Lock()
+			closed := s.closed
+			delete(s.listeners, ln)
+			s.lock.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			newSession(s, conn).run()
+		}()
+	}
+}
+
+// ServeHTTP accepts devices connected over WebSocket, mount it on /websocket
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	closed := s.closed
+	if !closed {
+		s.wg.Add(1)
+	}
+	s.lock.Unlock()
+	if closed {
+		http.Error(w, "server: closed", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.wg.Done()
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		slog.Printf("[DEBUG] server: websocket from %s failed, %s", r.RemoteAddr, err.Error())
+		return
+	}
+	newSession(s, conn).run()
+}
+
+// Close stops listeners, disconnects devices and saves the store
+func (s *Server) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.cancel)
+	for ln := range s.listeners {
+		ln.Close()
+	}
+	for _, sess := range s.sessions {
+		sess.close()
+	}
+	s.lock.Unlock()
+
+	s.wg.Wait()
+	return s.store.Save()
+}
+
+// Online returns tokens of the connected devices of the dashboard
+func (s *Server) Online(dashboard string) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var tokens []string
+	for sess := range s.dashboards[dashboard] {
+		tokens = append(tokens, sess.device.Token)
+	}
+	return tokens
+}
+
+// register adds the session, previous session of the same token is closed as the Blynk server does
+func (s *Server) register(sess *session) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return false
+	}
+	if old, ok := s.sessions[sess.device.Token]; ok {
+		slog.Printf("[INFO] server: device %s logged in again, closing previous connection", sess)
+		s.remove(old)
+		old.close()
+	}
+	s.sessions[sess.device.Token] = sess
+	dash := sess.device.dashboard()
+	if s.dashboards[dash] == nil {
+		s.dashboards[dash] = make(map[*session]bool)
+	}
+	s.dashboards[dash][sess] = true
+	return true
+}
+
+func (s *Server) unregister(sess *session) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.remove(sess)
+}
+
+func (s *Server) remove(sess *session) {
+	if s.sessions[sess.device.Token] == sess {
+		delete(s.sessions, sess.device.Token)
+	}
+	dash := sess.device.dashboard()
+	delete(s.dashboards[dash], sess)
+	if len(s.dashboards[dash]) == 0 {
+		delete(s.dashboards, dash)
+	}
+}
+
+// peers returns other connected devices of the session dashboard
+func (s *Server) peers(sess *session) []*session {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var peers []*session
+	for p := range s.dashboards[sess.device.dashboard()] {
+		if p != sess {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+func (s *Server) saver() {
+	defer s.wg.Done()
+	if s.saveInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.saveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.cancel:
+			return
+		case <-ticker.C:
+			if err := s.store.Save(); err != nil {
+				slog.Printf("[ERROR] server: save failed, %s", err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package server_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	blynk "github.com/OloloevReal/go-blynk"
+	"github.com/OloloevReal/go-blynk/server"
+)
+
+type pinWrite struct {
+	pin   uint
+	value string
+}
+
+func startServer(t *testing.T, store *server.Store) (*server.Server, int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.NewServer(store)
+	go srv.Serve(ln)
+	return srv, ln.Addr().(*net.TCPAddr).Port
+}
+
+// connect starts the device, values written to pin 5 by the server are sent to the returned channel
+func connect(t *testing.T, token string, port int) (*blynk.Blynk, chan pinWrite, error) {
+	t.Helper()
+	writes := make(chan pinWrite, 4)
+	device := blynk.NewBlynk(token)
+	device.DisableLogo(true)
+	device.SetServer("127.0.0.1", port, false)
+	device.AddWriterHandler(5, func(pin uint, r io.Reader) {
+		value, _ := io.ReadAll(r)
+		writes <- pinWrite{pin: pin, value: string(value)}
+	})
+	if err := device.Connect(); err != nil {
+		return nil, nil, err
+	}
+	go device.Processing()
+	return device, writes, nil
+}
+
+func expectWrite(t *testing.T, writes chan pinWrite, want pinWrite) {
+	t.Helper()
+	select {
+	case got := <-writes:
+		if got != want {
+			t.Fatalf("got write %+v, want %+v", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no write %+v", want)
+	}
+}
+
+func TestServerEndToEnd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, err := server.OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.AddDevice(server.Device{Token: "lamp", Dashboard: "home"})
+	store.AddDevice(server.Device{Token: "switch", Dashboard: "home"})
+	store.AddDevice(server.Device{Token: "garage", Dashboard: "garage"})
+	srv, port := startServer(t, store)
+
+	if _, _, err := connect(t, "unknown", port); !errors.Is(err, blynk.ErrInvalidToken) {
+		t.Fatalf("login with unknown token: %v, want ErrInvalidToken", err)
+	}
+
+	lamp, lampWrites, err := connect(t, "lamp", port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw, swWrites, err := connect(t, "switch", port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	garage, garageWrites, err := connect(t, "garage", port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// writes are routed to other devices of the dashboard only
+	if err := sw.VirtualWrite(5, "1"); err != nil {
+		t.Fatal(err)
+	}
+	expectWrite(t, lampWrites, pinWrite{5, "1"})
+	if err := lamp.VirtualWrite(5, "0"); err != nil {
+		t.Fatal(err)
+	}
+	expectWrite(t, swWrites, pinWrite{5, "0"})
+	select {
+	case w := <-garageWrites:
+		t.Fatalf("device of other dashboard got %+v", w)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for _, d := range []*blynk.Blynk{lamp, sw, garage} {
+		d.Stop()
+	}
+	if err := srv.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the last value is kept in the state file and sent after restart
+	store, err = server.OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := store.Pin("home", "V5"); !ok || !reflect.DeepEqual(v, []string{"0"}) {
+		t.Fatalf("reloaded V5 = %q, %v, want [0]", v, ok)
+	}
+	srv, port = startServer(t, store)
+	defer srv.Close()
+
+	lamp, lampWrites, err = connect(t, "lamp", port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lamp.Stop()
+	if err := lamp.VirtualRead(5); err != nil {
+		t.Fatal(err)
+	}
+	expectWrite(t, lampWrites, pinWrite{5, "0"})
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OloloevReal/go-blynk/protocol"
+	slog "github.com/OloloevReal/go-simple-log"
+)
+
+const SESSION_WRITE_TIMEOUT = time.Second * 10
+
+type session struct {
+	srv     *Server
+	conn    net.Conn
+	dec     *protocol.Decoder
+	lock    sync.Mutex
+	enc     *protocol.Encoder
+	msgID   uint16
+	device  Device
+	timeout time.Duration
+}
+
+func newSession(srv *Server, conn net.Conn) *session {
+	return &session{
+		srv:     srv,
+		conn:    conn,
+		dec:     protocol.NewDecoder(conn),
+		enc:     protocol.NewEncoder(conn),
+		timeout: heartbeatTimeout(SERVER_HEARTBEAT),
+	}
+}
+
+// heartbeatTimeout is the time without messages after which the device is disconnected
+func heartbeatTimeout(hb time.Duration) time.Duration {
+	return hb*2 + hb/3
+}
+
+func (s *session) String() string {
+	if s.device.Name != "" {
+		return s.device.Name
+	}
+	if len(s.device.Token) > 6 {
+		return s.device.Token[:6] + "..."
+	}
+	return s.conn.RemoteAddr().String()
+}
+
+func (s *session) close() {
+	s.conn.Close()
+}
+
+func (s *session) run() {
+	defer s.close()
+	if !s.login() {
+		return
+	}
+	defer s.srv.unregister(s)
+	slog.Printf("[INFO] server: device %s connected from %s", s, s.conn.RemoteAddr())
+	defer slog.Printf("[INFO] server: device %s disconnected", s)
+
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(s.timeout))
+		f, err := s.dec.Decode()
+		if err != nil {
+			slog.Printf("[DEBUG] server: device %s, read failed, %s", s, err.Error())
+			return
+		}
+
+		switch f.Command {
+		case protocol.CMD_PING:
+			s.respond(f.MessageId, protocol.STATUS_SUCCESS)
+		case protocol.CMD_INTERNAL:
+			s.internal(f)
+		case protocol.CMD_HARDWARE:
+			s.hardware(f)
+		case protocol.CMD_HARDWARE_SYNC:
+			s.sync(f)
+		case protocol.CMD_RESPONSE:
+		default:
+			slog.Printf("[DEBUG] server: device %s, unsupported command %s", s, f.Command)
+			s.respond(f.MessageId, protocol.STATUS_ILLEGAL_COMMAND)
+		}
+	}
+}
+
+func (s *session) login() bool {
+	s.conn.SetReadDeadline(time.Now().Add(SERVER_LOGIN_TIMEOUT))
+	f, err := s.dec.Decode()
+	if err != nil {
+		slog.Printf("[DEBUG] server: login from %s failed, %s", s.conn.RemoteAddr(), err.Error())
+		return false
+	}
+	if f.Command != protocol.CMD_HW_LOGIN {
+		s.respond(f.MessageId, protocol.STATUS_NOT_AUTHENTICATED)
+		return false
+	}
+
+	values := f.Values()
+	device, ok := Device{}, false
+	if len(values) > 0 {
+		device, ok = s.srv.store.Device(values[0])
+	}
+	if !ok {
+		slog.Printf("[INFO] server: invalid token from %s", s.conn.RemoteAddr())
+		s.respond(f.MessageId, protocol.STATUS_INVALID_TOKEN)
+		return false
+	}
+	s.device = device
+	if !s.srv.register(s) {
+		return false
+	}
+	return s.respond(f.MessageId, protocol.STATUS_SUCCESS) == nil
+}
+
+// internal reads the heartbeat interval of the device, other parameters are accepted as is
+func (s *session) internal(f *protocol.Frame) {
+	values := f.Values()
+	for i := 0; i+1 < len(values); i += 2 {
+		if values[i] != "h-beat" {
+			continue
+		}
+		if sec, err := strconv.Atoi(values[i+1]); err == nil && sec > 0 {
+			s.timeout = heartbeatTimeout(time.Duration(sec) * time.Second)
+		}
+	}
+	s.respond(f.MessageId, protocol.STATUS_SUCCESS)
+}
+
+// hardware stores and routes writes, reads are answered from the store or routed to the dashboard devices
+func (s *session) hardware(f *protocol.Frame) {
+	values := f.Values()
+	if len(values) < 2 {
+		s.respond(f.MessageId, protocol.STATUS_ILLEGAL_COMMAND_BODY)
+		return
+	}
+
+	switch values[0] {
+	case "vw", "dw", "aw":
+		pin, ok := pinKey(values[0], values[1])
+		if !ok || len(values) < 3 {
+			s.respond(f.MessageId, protocol.STATUS_ILLEGAL_COMMAND_BODY)
+			return
+		}
+		s.srv.store.SetPin(s.device.dashboard(), pin, values[2:])
+		for _, p := range s.srv.peers(s) {
+			p.send(values...)
+		}
+	case "vr", "dr", "ar":
+		s.read(values[0], values[1:])
+	case "pm":
+		// pin modes are set on the device side, nothing to keep
+	default:
+		s.respond(f.MessageId, protocol.STATUS_ILLEGAL_COMMAND_BODY)
+	}
+}
+
+// sync answers HARDWARE_SYNC, without pins all stored values of the dashboard are sent
+func (s *session) sync(f *protocol.Frame) {
+	values := f.Values()
+	if len(values) == 0 {
+		for pin, v := range s.srv.store.Pins(s.device.dashboard()) {
+			s.send(append([]string{writeOp(pin), pin[1:]}, v...)...)
+		}
+		return
+	}
+	switch values[0] {
+	case "vr", "dr", "ar":
+		s.read(values[0], values[1:])
+	default:
+		s.respond(f.MessageId, protocol.STATUS_ILLEGAL_COMMAND_BODY)
+	}
+}
+
+func (s *session) read(op string, pins []string) {
+	dash := s.device.dashboard()
+	for _, pin := range pins {
+		key, ok := pinKey(op, pin)
+		if !ok {
+			continue
+		}
+		if v, ok := s.srv.store.Pin(dash, key); ok {
+			s.send(append([]string{writeOp(key), pin}, v...)...)
+			continue
+		}
+		// no stored value, the reader handlers of other devices answer with writes routed back
+		for _, p := range s.srv.peers(s) {
+			p.send(op, pin)
+		}
+	}
+}
+
+func (s *session) send(values ...string) {
+	s.lock.Lock()
+	s.msgID++
+	id := s.msgID
+	s.lock.Unlock()
+
+	f, err := protocol.NewFrame(protocol.CMD_HARDWARE, id, values...)
+	if err != nil {
+		slog.Printf("[ERROR] server: device %s, %s", s, err.Error())
+		return
+	}
+	if err := s.write(f); err != nil {
+		slog.Printf("[DEBUG] server: device %s, write failed, %s", s, err.Error())
+		s.close()
+	}
+}
+
+func (s *session) respond(id uint16, status uint16) error {
+	return s.write(&protocol.Frame{Command: protocol.CMD_RESPONSE, MessageId: id, Length: status})
+}
+
+func (s *session) write(f *protocol.Frame) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(SESSION_WRITE_TIMEOUT))
+	return s.enc.Encode(f)
+}
+
+// pinKey returns store key of the pin like "V5", op is a hardware operation like "vw" or "dr"
+func pinKey(op string, pin string) (string, bool) {
+	if _, err := strconv.ParseUint(pin, 10, 8); err != nil {
+		return "", false
+	}
+	return strings.ToUpper(op[:1]) + pin, true
+}
+
+func writeOp(key string) string {
+	return strings.ToLower(key[:1]) + "w"
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Device is a hardware token, devices of one dashboard share pin values
+type Device struct {
+	Token     string `json:"token"`
+	Name      string `json:"name,omitempty"`
+	Dashboard string `json:"dashboard,omitempty"`
+}
+
+func (d *Device) dashboard() string {
+	if d.Dashboard == "" {
+		return d.Token
+	}
+	return d.Dashboard
+}
+
+type state struct {
+	Devices []*Device                      `json:"devices"`
+	Pins    map[string]map[string][]string `json:"pins"`
+}
+
+// Store keeps tokens and last pin values of the dashboards, it is saved to the file as JSON
+type Store struct {
+	lock    sync.Mutex
+	path    string
+	devices map[string]*Device
+	pins    map[string]map[string][]string
+	dirty   bool
+}
+
+func NewStore() *Store {
+	return &Store{
+		devices: make(map[string]*Device),
+		pins:    make(map[string]map[string][]string),
+	}
+}
+
+// OpenStore loads the store from the file, missing file gives empty store which is saved to the path
+func OpenStore(path string) (*Store, error) {
+	s := NewStore()
+	s.path = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("store: bad file %s, %s", path, err.Error())
+	}
+	for _, d := range st.Devices {
+		if d.Token == "" {
+			return nil, fmt.Errorf("store: device without token in %s", path)
+		}
+		s.devices[d.Token] = d
+	}
+	for dash, pins := range st.Pins {
+		s.pins[dash] = pins
+	}
+	return s, nil
+}
+
+func (s *Store) AddDevice(d Device) error {
+	if d.Token == "" {
+		return fmt.Errorf("store: empty token")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.devices[d.Token]; ok {
+		return fmt.Errorf("store: device already exists")
+	}
+	s.devices[d.Token] = &d
+	s.dirty = true
+	return nil
+}
+
+func (s *Store) Device(token string) (Device, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	d, ok := s.devices[token]
+	if !ok {
+		return Device{}, false
+	}
+	return *d, true
+}
+
+// SetPin keeps the last value of the pin, pin is "V5", "D3" or "A0"
+func (s *Store) SetPin(dashboard string, pin string, values []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	pins, ok := s.pins[dashboard]
+	if !ok {
+		pins = make(map[string][]string)
+		s.pins[dashboard] = pins
+	}
+	pins[pin] = append([]string(nil), values...)
+	s.dirty = true
+}
+
+func (s *Store) Pin(dashboard string, pin string) ([]string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	values, ok := s.pins[dashboard][pin]
+	return append([]string(nil), values...), ok
+}
+
+// Pins returns a copy of all pin values of the dashboard
+func (s *Store) Pins(dashboard string) map[string][]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	pins := make(map[string][]string, len(s.pins[dashboard]))
+	for pin, values := range s.pins[dashboard] {
+		pins[pin] = append([]string(nil), values...)
+	}
+	return pins
+}
+
+// Save writes the store to its file if it was changed, the file is replaced atomically
+func (s *Store) Save() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.path == "" || !s.dirty {
+		return nil
+	}
+
+	st := state{Pins: s.pins}
+	for _, d := range s.devices {
+		st.Devices = append(st.Devices, d)
+	}
+	sort.Slice(st.Devices, func(i, j int) bool { return st.Devices[i].Token < st.Devices[j].Token })
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	s.dirty = false
+	return nil
+}
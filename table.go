@@ -0,0 +1,176 @@
+package blynk
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+type TableRow struct {
+	ID       int
+	Name     string
+	Value    string
+	Selected bool
+}
+
+type Table struct {
+	blynk      *Blynk
+	pin        int
+	lock       sync.Mutex
+	rows       []TableRow
+	OnSelect   func(row TableRow)
+	OnDeselect func(row TableRow)
+	OnOrder    func(from int, to int)
+}
+
+// NewTable binds Table widget to the virtual pin and subscribes to the row events from the app
+func (g *Blynk) NewTable(pin int) *Table {
+	t := &Table{blynk: g, pin: pin}
+	g.setValuesHandler(uint(pin), t.handle)
+	return t
+}
+
+func (t *Table) Close() {
+	t.blynk.deleteValuesHandler(uint(t.pin))
+}
+
+func (t *Table) Rows() []TableRow {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	rows := make([]TableRow, len(t.rows))
+	copy(rows, t.rows)
+	return rows
+}
+
+func (t *Table) Clear() error {
+	t.lock.Lock()
+	t.rows = nil
+	t.lock.Unlock()
+	return t.blynk.VirtualWrite(t.pin, "clr")
+}
+
+func (t *Table) Add(id int, name string, value string) error {
+	t.lock.Lock()
+	if t.indexOf(id) >= 0 {
+		t.lock.Unlock()
+		return fmt.Errorf("table: row %d already exists", id)
+	}
+	t.rows = append(t.rows, TableRow{ID: id, Name: name, Value: value})
+	t.lock.Unlock()
+	return t.blynk.VirtualWrite(t.pin, "add", strconv.Itoa(id), name, value)
+}
+
+func (t *Table) Update(id int, name string, value string) error {
+	t.lock.Lock()
+	i := t.indexOf(id)
+	if i < 0 {
+		t.lock.Unlock()
+		return fmt.Errorf("table: row %d not found", id)
+	}
+	t.rows[i].Name = name
+	t.rows[i].Value = value
+	t.lock.Unlock()
+	return t.blynk.VirtualWrite(t.pin, "update", strconv.Itoa(id), name, value)
+}
+
+func (t *Table) Pick(id int) error {
+	return t.blynk.VirtualWrite(t.pin, "pick", strconv.Itoa(id))
+}
+
+func (t *Table) Select(id int) error {
+	if err := t.setSelected(id, true); err != nil {
+		return err
+	}
+	return t.blynk.VirtualWrite(t.pin, "select", strconv.Itoa(id))
+}
+
+func (t *Table) Deselect(id int) error {
+	if err := t.setSelected(id, false); err != nil {
+		return err
+	}
+	return t.blynk.VirtualWrite(t.pin, "deselect", strconv.Itoa(id))
+}
+
+func (t *Table) setSelected(id int, selected bool) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	i := t.indexOf(id)
+	if i < 0 {
+		return fmt.Errorf("table: row %d not found", id)
+	}
+	t.rows[i].Selected = selected
+	return nil
+}
+
+func (t *Table) indexOf(id int) int {
+	for i, row := range t.rows {
+		if row.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *Table) handle(pin uint, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	switch values[0] {
+	case "select", "deselect":
+		if len(values) < 2 {
+			t.blynk.log.Printf("[ERROR] table: %s without row id, Pin: %d", values[0], pin)
+			return
+		}
+		id, err := strconv.Atoi(values[1])
+		if err != nil {
+			t.blynk.log.Printf("[ERROR] table: bad row id %q, Pin: %d", values[1], pin)
+			return
+		}
+		selected := values[0] == "select"
+		t.lock.Lock()
+		i := t.indexOf(id)
+		var row TableRow
+		if i >= 0 {
+			t.rows[i].Selected = selected
+			row = t.rows[i]
+		} else {
+			row = TableRow{ID: id, Selected: selected}
+		}
+		t.lock.Unlock()
+
+		if selected && t.OnSelect != nil {
+			t.OnSelect(row)
+		} else if !selected && t.OnDeselect != nil {
+			t.OnDeselect(row)
+		}
+	case "order":
+		if len(values) < 3 {
+			t.blynk.log.Printf("[ERROR] table: order without indexes, Pin: %d", pin)
+			return
+		}
+		from, err1 := strconv.Atoi(values[1])
+		to, err2 := strconv.Atoi(values[2])
+		if err1 != nil || err2 != nil {
+			t.blynk.log.Printf("[ERROR] table: bad order indexes %q %q, Pin: %d", values[1], values[2], pin)
+			return
+		}
+		t.lock.Lock()
+		if from >= 0 && from < len(t.rows) && to >= 0 && to < len(t.rows) {
+			row := t.rows[from]
+			t.rows = append(t.rows[:from], t.rows[from+1:]...)
+			t.rows = append(t.rows[:to], append([]TableRow{row}, t.rows[to:]...)...)
+		}
+		t.lock.Unlock()
+
+		if t.OnOrder != nil {
+			t.OnOrder(from, to)
+		}
+	case "clr":
+		t.lock.Lock()
+		t.rows = nil
+		t.lock.Unlock()
+	default:
+		t.blynk.log.Printf("[DEBUG] table: unhandled command %q, Pin: %d", values[0], pin)
+	}
+}
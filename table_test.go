@@ -0,0 +1,88 @@
+package blynk
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTableFrames(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(tb *Table) error
+		want []string
+	}{
+		{"add", func(tb *Table) error { return tb.Add(1, "Temp", "21") },
+			[]string{"vw\x007\x00add\x001\x00Temp\x0021"}},
+		{"update", func(tb *Table) error {
+			tb.Add(1, "Temp", "21")
+			return tb.Update(1, "Temp", "22.5")
+		}, []string{"vw\x007\x00add\x001\x00Temp\x0021", "vw\x007\x00update\x001\x00Temp\x0022.5"}},
+		{"pick", func(tb *Table) error { return tb.Pick(3) },
+			[]string{"vw\x007\x00pick\x003"}},
+		{"select", func(tb *Table) error {
+			tb.Add(2, "Door", "open")
+			return tb.Select(2)
+		}, []string{"vw\x007\x00add\x002\x00Door\x00open", "vw\x007\x00select\x002"}},
+		{"clear", func(tb *Table) error { return tb.Clear() },
+			[]string{"vw\x007\x00clr"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, conn := newRecordBlynk()
+			if err := tt.call(g.NewTable(7)); err != nil {
+				t.Fatal(err)
+			}
+			if got := frameBodies(conn.Take()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("bodies\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableErrors(t *testing.T) {
+	g, conn := newRecordBlynk()
+	tb := g.NewTable(7)
+	tb.Add(1, "a", "b")
+	conn.Take()
+
+	if err := tb.Add(1, "a", "b"); err == nil {
+		t.Error("add of existing row: expected error")
+	}
+	if err := tb.Update(2, "a", "b"); err == nil {
+		t.Error("update of missing row: expected error")
+	}
+	if err := tb.Select(2); err == nil {
+		t.Error("select of missing row: expected error")
+	}
+	if b := conn.Take(); len(b) != 0 {
+		t.Errorf("nothing should be sent, got %q", b)
+	}
+}
+
+func TestTableEvents(t *testing.T) {
+	g, _ := newRecordBlynk()
+	tb := g.NewTable(7)
+	tb.Add(1, "a", "1")
+	tb.Add(2, "b", "2")
+	var selected TableRow
+	var order [2]int
+	tb.OnSelect = func(row TableRow) { selected = row }
+	tb.OnOrder = func(from int, to int) { order = [2]int{from, to} }
+
+	tb.handle(7, []string{"select", "2"})
+	if selected != (TableRow{ID: 2, Name: "b", Value: "2", Selected: true}) {
+		t.Fatalf("selected %+v", selected)
+	}
+	tb.handle(7, []string{"order", "1", "0"})
+	if order != [2]int{1, 0} {
+		t.Fatalf("order %v", order)
+	}
+	if rows := tb.Rows(); rows[0].ID != 2 || rows[1].ID != 1 {
+		t.Fatalf("rows %+v", rows)
+	}
+	tb.handle(7, []string{"clr"})
+	if len(tb.Rows()) != 0 {
+		t.Fatal("rows are not cleared")
+	}
+}
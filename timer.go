@@ -0,0 +1,263 @@
+package blynk
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	slog "github.com/OloloevReal/go-simple-log"
+)
+
+const (
+	TIMER_IDLE_WAIT    = time.Second
+	TIMER_MIN_INTERVAL = time.Millisecond * 10
+)
+
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+type timerEntry struct {
+	owner    *Timer
+	interval time.Duration
+	fn       func()
+	next     time.Time
+	runs     int
+	maxRuns  int
+	enabled  bool
+}
+
+// timerWheel keeps entries of all timers sharing one goroutine
+type timerWheel struct {
+	clock   Clock
+	lock    sync.Mutex
+	entries map[int]*timerEntry
+	lastID  int
+	wake    chan struct{}
+}
+
+// Timer runs callbacks in the style of BlynkTimer, callbacks are called one by one on the timer goroutine.
+// Timers created by Sub share the goroutine but are paused and cleared independently.
+type Timer struct {
+	wheel  *timerWheel
+	paused bool
+}
+
+func NewTimer(clock Clock) *Timer {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &Timer{
+		wheel: &timerWheel{
+			clock:   clock,
+			entries: make(map[int]*timerEntry),
+			wake:    make(chan struct{}, 1),
+		},
+	}
+}
+
+// Sub returns a new timer running on the same goroutine
+func (t *Timer) Sub() *Timer {
+	return &Timer{wheel: t.wheel}
+}
+
+func (t *Timer) setClock(clock Clock) {
+	t.wheel.lock.Lock()
+	defer t.wheel.lock.Unlock()
+	t.wheel.clock = clock
+}
+
+func (t *Timer) SetInterval(d time.Duration, fn func()) int {
+	return t.SetTimer(d, fn, 0)
+}
+
+func (t *Timer) SetTimeout(d time.Duration, fn func()) int {
+	return t.SetTimer(d, fn, 1)
+}
+
+// SetTimer calls fn every d n times, zero n means forever.
+// Intervals shorter than TIMER_MIN_INTERVAL are raised to it, so the goroutine does not spin
+func (t *Timer) SetTimer(d time.Duration, fn func(), n int) int {
+	if d < TIMER_MIN_INTERVAL {
+		d = TIMER_MIN_INTERVAL
+	}
+	w := t.wheel
+	w.lock.Lock()
+	w.lastID++
+	id := w.lastID
+	w.entries[id] = &timerEntry{
+		owner:    t,
+		interval: d,
+		fn:       fn,
+		next:     w.clock.Now().Add(d),
+		maxRuns:  n,
+		enabled:  true,
+	}
+	w.lock.Unlock()
+	w.notify()
+	return id
+}
+
+func (t *Timer) Enable(id int) {
+	t.setEnabled(id, true)
+}
+
+func (t *Timer) Disable(id int) {
+	t.setEnabled(id, false)
+}
+
+func (t *Timer) IsEnabled(id int) bool {
+	t.wheel.lock.Lock()
+	defer t.wheel.lock.Unlock()
+	e := t.entry(id)
+	return e != nil && e.enabled
+}
+
+// Restart resets the countdown and the number of runs of the timer
+func (t *Timer) Restart(id int) {
+	w := t.wheel
+	w.lock.Lock()
+	if e := t.entry(id); e != nil {
+		e.next = w.clock.Now().Add(e.interval)
+		e.runs = 0
+	}
+	w.lock.Unlock()
+	w.notify()
+}
+
+func (t *Timer) Delete(id int) {
+	t.wheel.lock.Lock()
+	defer t.wheel.lock.Unlock()
+	if t.entry(id) != nil {
+		delete(t.wheel.entries, id)
+	}
+}
+
+// DeleteAll removes all entries of the timer, entries of other timers on the same goroutine are kept
+func (t *Timer) DeleteAll() {
+	t.wheel.lock.Lock()
+	defer t.wheel.lock.Unlock()
+	for id, e := range t.wheel.entries {
+		if e.owner == t {
+			delete(t.wheel.entries, id)
+		}
+	}
+}
+
+func (t *Timer) Pause() {
+	t.wheel.lock.Lock()
+	defer t.wheel.lock.Unlock()
+	t.paused = true
+}
+
+func (t *Timer) Resume() {
+	t.wheel.lock.Lock()
+	t.paused = false
+	t.wheel.lock.Unlock()
+	t.wheel.notify()
+}
+
+func (t *Timer) entry(id int) *timerEntry {
+	if e, ok := t.wheel.entries[id]; ok && e.owner == t {
+		return e
+	}
+	return nil
+}
+
+func (t *Timer) setEnabled(id int, enabled bool) {
+	w := t.wheel
+	w.lock.Lock()
+	if e := t.entry(id); e != nil {
+		if enabled && !e.enabled {
+			e.next = w.clock.Now().Add(e.interval)
+		}
+		e.enabled = enabled
+	}
+	w.lock.Unlock()
+	w.notify()
+}
+
+// Run calls all due callbacks of the goroutine and returns the time of the nearest next run
+func (t *Timer) Run() time.Time {
+	return t.wheel.run()
+}
+
+func (t *Timer) loop(cancel <-chan bool) {
+	t.wheel.loop(cancel)
+}
+
+func (w *timerWheel) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *timerWheel) run() time.Time {
+	var due []func()
+	w.lock.Lock()
+	now := w.clock.Now()
+	next := now.Add(TIMER_IDLE_WAIT)
+	ids := make([]int, 0, len(w.entries))
+	for id := range w.entries {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		e := w.entries[id]
+		// paused entries wait for Resume, an overdue one must not shorten the wait
+		if !e.enabled || e.owner.paused {
+			continue
+		}
+		if !e.next.After(now) {
+			due = append(due, e.fn)
+			e.runs++
+			if e.maxRuns > 0 && e.runs >= e.maxRuns {
+				delete(w.entries, id)
+				continue
+			}
+			e.next = e.next.Add(e.interval)
+			if !e.next.After(now) {
+				e.next = now.Add(e.interval)
+			}
+		}
+		if e.next.Before(next) {
+			next = e.next
+		}
+	}
+	w.lock.Unlock()
+
+	for _, fn := range due {
+		fn()
+	}
+	return next
+}
+
+func (w *timerWheel) loop(cancel <-chan bool) {
+	slog.Printf("Timer: started")
+	defer slog.Printf("Timer: finished")
+	for {
+		next := w.run()
+		w.lock.Lock()
+		clock := w.clock
+		w.lock.Unlock()
+		select {
+		case <-clock.After(next.Sub(clock.Now())):
+		case <-w.wake:
+		case <-cancel:
+			slog.Printf("[DEBUG] Timer: Stop received")
+			return
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package blynk
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimerInterval(t *testing.T) {
+	clock := newFakeClock()
+	timer := NewTimer(clock)
+	runs := 0
+	timer.SetInterval(time.Second, func() { runs++ })
+
+	timer.Run()
+	if runs != 0 {
+		t.Fatalf("runs %d before the interval", runs)
+	}
+	for i := 1; i <= 3; i++ {
+		clock.Advance(time.Second)
+		if next := timer.Run(); !next.Equal(clock.Now().Add(time.Second)) {
+			t.Fatalf("next run at %v, want %v", next, clock.Now().Add(time.Second))
+		}
+		if runs != i {
+			t.Fatalf("runs %d, want %d", runs, i)
+		}
+	}
+}
+
+func TestTimerTimeoutAndCount(t *testing.T) {
+	clock := newFakeClock()
+	timer := NewTimer(clock)
+	once, three := 0, 0
+	timer.SetTimeout(time.Second, func() { once++ })
+	id := timer.SetTimer(time.Second, func() { three++ }, 3)
+
+	for i := 0; i < 5; i++ {
+		clock.Advance(time.Second)
+		timer.Run()
+	}
+	if once != 1 || three != 3 {
+		t.Fatalf("timeout runs %d, counted runs %d, want 1 and 3", once, three)
+	}
+	if timer.IsEnabled(id) {
+		t.Fatal("finished timer is not deleted")
+	}
+}
+
+func TestTimerDisableRestart(t *testing.T) {
+	clock := newFakeClock()
+	timer := NewTimer(clock)
+	runs := 0
+	id := timer.SetInterval(time.Second, func() { runs++ })
+
+	timer.Disable(id)
+	clock.Advance(time.Second)
+	timer.Run()
+	if runs != 0 {
+		t.Fatal("disabled timer is called")
+	}
+
+	timer.Enable(id)
+	clock.Advance(time.Second / 2)
+	timer.Restart(id)
+	clock.Advance(time.Second / 2)
+	timer.Run()
+	if runs != 0 {
+		t.Fatal("restart does not reset the countdown")
+	}
+	clock.Advance(time.Second / 2)
+	timer.Run()
+	if runs != 1 {
+		t.Fatalf("runs %d, want 1", runs)
+	}
+}
+
+func TestTimerPauseResume(t *testing.T) {
+	clock := newFakeClock()
+	timer := NewTimer(clock)
+	runs := 0
+	timer.SetInterval(10*time.Millisecond, func() { runs++ })
+
+	timer.Pause()
+	clock.Advance(time.Second)
+	next := timer.Run()
+	if runs != 0 {
+		t.Fatal("paused timer is called")
+	}
+	// an overdue paused entry must not make the goroutine wake up at once
+	if want := clock.Now().Add(TIMER_IDLE_WAIT); !next.Equal(want) {
+		t.Fatalf("next run at %v, want %v", next, want)
+	}
+
+	timer.Resume()
+	timer.Run()
+	if runs != 1 {
+		t.Fatalf("runs %d after resume, want 1", runs)
+	}
+}
+
+func TestTimerSub(t *testing.T) {
+	clock := newFakeClock()
+	timer := NewTimer(clock)
+	sub := timer.Sub()
+	parent, child := 0, 0
+	timer.SetInterval(time.Second, func() { parent++ })
+	sub.SetInterval(time.Second, func() { child++ })
+
+	sub.Pause()
+	clock.Advance(time.Second)
+	timer.Run()
+	if parent != 1 || child != 0 {
+		t.Fatalf("parent %d, child %d, want 1 and 0", parent, child)
+	}
+
+	sub.Resume()
+	sub.DeleteAll()
+	clock.Advance(time.Second)
+	timer.Run()
+	if parent != 2 || child != 0 {
+		t.Fatalf("parent %d, child %d, want 2 and 0", parent, child)
+	}
+}
+
+func TestTimerLoopPausedDoesNotSpin(t *testing.T) {
+	clock := newFakeClock()
+	timer := NewTimer(clock)
+	timer.SetInterval(10*time.Millisecond, func() {})
+	timer.Pause()
+	clock.Advance(time.Second)
+
+	cancel := make(chan bool)
+	done := make(chan struct{})
+	go func() {
+		timer.loop(cancel)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(cancel)
+	<-done
+
+	// one more wait is made after the wake up left by SetInterval
+	waits := clock.Waits()
+	if len(waits) > 2 {
+		t.Fatalf("loop waited %d times in 50ms", len(waits))
+	}
+	for _, d := range waits {
+		if d != TIMER_IDLE_WAIT {
+			t.Fatalf("loop waits %v, want %v", d, TIMER_IDLE_WAIT)
+		}
+	}
+}
+
+func TestTimerMinInterval(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second, time.Millisecond} {
+		clock := newFakeClock()
+		timer := NewTimer(clock)
+		runs := 0
+		timer.SetInterval(d, func() { runs++ })
+
+		if next := timer.Run(); runs != 0 || !next.Equal(clock.Now().Add(TIMER_MIN_INTERVAL)) {
+			t.Fatalf("interval %v: runs %d, next run at %v", d, runs, next)
+		}
+		clock.Advance(TIMER_MIN_INTERVAL)
+		if next := timer.Run(); runs != 1 || !next.After(clock.Now()) {
+			t.Fatalf("interval %v: runs %d, next run at %v", d, runs, next)
+		}
+	}
+}
@@ -6,16 +6,19 @@ import (
 	"fmt"
 	"io"
 	"net"
-	"strconv"
+	"strings"
 	"time"
-
-	slog "github.com/OloloevReal/go-simple-log"
 )
 
 func (g *Blynk) sendMessage(msg BlynkMessage) (uint16, error) {
-	if err := g.sendBytes(msg.GetBytes()); err != nil {
+	buf, err := msg.MarshalBinary()
+	if err != nil {
 		return 0, err
 	}
+	if err := g.sendBytes(buf); err != nil {
+		return 0, err
+	}
+	g.metrics.MessageSent(msg.Head.Command, len(buf))
 	return msg.Head.MessageId, nil
 }
 
@@ -32,23 +35,92 @@ func (g *Blynk) sendString(cmd BlynkCommand, data string) (uint16, error) {
 		return 0, fmt.Errorf("send: conn *net.TCPConn is nil")
 	}
 
+	msg := g.stringMessage(cmd, data)
+	if _, err := g.sendMessage(msg); err != nil {
+		return msg.Head.MessageId, err
+	}
+
+	return msg.Head.MessageId, nil
+}
+
+func (g *Blynk) stringMessage(cmd BlynkCommand, data string) BlynkMessage {
 	msg := BlynkMessage{}
 	msg.Head.Command = cmd
 	msg.Head.MessageId = g.getMessageID()
 	msg.Body.AddString(data)
 	msg.Head.Length = msg.Body.Len()
+	return msg
+}
 
-	err := g.sendBytes(msg.GetBytes())
-	if err != nil {
-		return msg.Head.MessageId, err
+// sendWithStatus sends the message and waits for the status of the server,
+// while Processing is running the processor passes the status by message id
+func (g *Blynk) sendWithStatus(msg BlynkMessage) error {
+	id := msg.Head.MessageId
+	cmd := msg.Head.Command
+	var status chan uint16
+	if g.processingUsing {
+		status = make(chan uint16, 1)
+		g.lock.Lock()
+		g.statusWaiters[id] = status
+		g.lock.Unlock()
+		defer func() {
+			g.lock.Lock()
+			delete(g.statusWaiters, id)
+			g.lock.Unlock()
+		}()
 	}
 
-	return msg.Head.MessageId, nil
+	if _, err := g.sendMessage(msg); err != nil {
+		return fmt.Errorf("send %s failed, %s", strings.ToLower(cmd.String()), err.Error())
+	}
+
+	if status == nil {
+		bh, err := g.receiveMessage(g.timeoutMAX)
+		if err != nil {
+			return err
+		}
+		if bh.Length != BLYNK_SUCCESS {
+			return newStatusError(cmd, bh)
+		}
+		return nil
+	}
+
+	select {
+	case code := <-status:
+		if code != BLYNK_SUCCESS {
+			return &StatusError{Code: code, Command: cmd, MessageID: id}
+		}
+		return nil
+	case <-time.After(g.timeoutMAX):
+		return fmt.Errorf("%s: no status, timeout", strings.ToLower(cmd.String()))
+	}
+}
+
+// statusReceived passes the response to the command waiting for it and reports whether one was waiting
+func (g *Blynk) statusReceived(resp *BlynkRespose) bool {
+	g.lock.Lock()
+	status, ok := g.statusWaiters[resp.MessageId]
+	delete(g.statusWaiters, resp.MessageId)
+	g.lock.Unlock()
+	if ok {
+		status <- resp.Status
+	}
+	return ok
 }
 
 func (g *Blynk) sendBytes(buf []byte) error {
-	_, err := g.conn.Write(buf)
-	return err
+	//Connect replaces the connection while other goroutines are sending
+	g.lock.Lock()
+	conn := g.conn
+	g.lock.Unlock()
+	if conn == nil {
+		return fmt.Errorf("send: not connected")
+	}
+	if _, err := conn.Write(buf); err != nil {
+		return err
+	}
+	g.recorder.Sent(buf)
+	return nil
 }
 
 func (g *Blynk) receiveMessage(timeout time.Duration) (*BlynkHead, error) {
@@ -62,7 +134,7 @@ func (g *Blynk) receiveMessage(timeout time.Duration) (*BlynkHead, error) {
 
 	err = binary.Read(bufReader, binary.BigEndian, resp)
 	if err != nil {
-		slog.Printf("[DEBUG] receiveMessage: binary read error, ", err.Error())
+		g.log.Printf("[DEBUG] receiveMessage: binary read error, %s", err.Error())
 		return nil, err
 	}
 
@@ -81,147 +153,213 @@ func (g *Blynk) receive(timeout time.Duration) ([]byte, error) {
 	buf := make([]byte, 1024)
 	cnt, err := g.conn.Read(buf)
 	if err == io.EOF {
-		slog.Printf("[DEBUG] receive: EOF")
+		g.log.Printf("[DEBUG] receive: EOF")
 		return nil, err
 	}
 
 	if err2, ok := err.(net.Error); ok && err2.Timeout() {
-		slog.Printf("[DEBUG] is timeout: %v %d\n", err2.Timeout(), cnt)
+		g.log.Printf("[DEBUG] is timeout: %v %d\n", err2.Timeout(), cnt)
 		return nil, err2
 	}
 
 	if err != nil {
-		slog.Printf("[DEBUG] receive: error, ", err.Error())
+		g.log.Printf("[DEBUG] receive: error, %s", err.Error())
 		return nil, err
 	}
 
-	return buf, nil
+	g.recorder.Received(buf[:cnt])
+	return buf[:cnt], nil
 }
 
 func (g *Blynk) receiver() error {
-	slog.Printf("[INFO] Receiver: started")
-	defer slog.Printf("[INFO] Receiver: finished")
+	g.log.Printf("[INFO] Receiver: started")
+	defer g.log.Printf("[INFO] Receiver: finished")
 	if g == nil || g.conn == nil {
 		return fmt.Errorf("receiver: *Blynk or *net.TCPConn is nil")
 	}
 	g.conn.SetReadDeadline(time.Time{})
+	if !g.managed {
+		//nil tells the processor that the tail of the previous connection is garbage
+		select {
+		case g.recvMsg <- nil:
+		case <-g.cancel:
+			return nil
+		}
+	}
 	buf := make([]byte, 1024)
+	var pending []byte
 	for {
 		select {
 		case <-g.cancel:
-			slog.Printf("[DEBUG] receiver: cancel received")
+			g.log.Printf("[DEBUG] receiver: cancel received")
 			return nil
 		default:
 			{
 				cntBytes, err := g.conn.Read(buf)
 				if err == io.EOF {
-					slog.Printf("[DEBUG] receiver: EOF")
-					break
+					g.log.Printf("[DEBUG] receiver: EOF")
+					return err
 				}
 				if err2, ok := err.(net.Error); ok && err2.Timeout() {
-					slog.Printf("[DEBUG] receiver: is timeout: %v\n", err2.Timeout())
+					g.log.Printf("[DEBUG] receiver: is timeout: %v\n", err2.Timeout())
 					break
 				}
 				if err != nil {
-					slog.Printf("[ERROR] receiver: error, %s", err.Error())
+					g.log.Printf("[ERROR] receiver: error, %s", err.Error())
 					return err
 				}
-				//slog.Printf("[DEBUG] receiver send: % x", buf[:cntBytes])
+				g.recorder.Received(buf[:cntBytes])
+				//managed devices have no processor goroutine
+				if g.managed {
+					pending = g.process(pending, buf[:cntBytes])
+					break
+				}
+				//g.log.Printf("[DEBUG] receiver send: % x", buf[:cntBytes])
 				bufToSend := make([]byte, cntBytes)
 				copy(bufToSend, buf[:cntBytes])
-				g.recvMsg <- bufToSend
+				select {
+				case g.recvMsg <- bufToSend:
+				case <-g.cancel:
+					return nil
+				}
+				g.metrics.QueueDepth(len(g.recvMsg))
 			}
 		}
 	}
-
-	return nil
 }
 
 func (g *Blynk) processor() {
-	slog.Printf("Processor: started")
-	defer slog.Printf("Processor: finished")
+	g.log.Printf("Processor: started")
+	defer g.log.Printf("Processor: finished")
+	var pending []byte
 	for {
 		select {
 		case <-g.cancel:
-			slog.Printf("[DEBUG] Processor: Stop received")
+			g.log.Printf("[DEBUG] Processor: Stop received")
 			return
 		case buf := <-g.recvMsg:
-			{
-				//slog.Printf("[DEBUG] processor received msg: % x", buf)
-				br, err := g.parseResponce(buf)
-				if err != nil {
-					slog.Printf("[ERROR] processor: error parsing, %s", err.Error())
-				}
-
-				for _, resp := range br {
-					switch resp.Command {
-					case BLYNK_CMD_HARDWARE:
-						if g.OnReadFunc != nil {
-							g.OnReadFunc(resp)
-						}
-
-						switch resp.Values[0] {
-						case "vr":
-							pin, _ := strconv.Atoi(resp.Values[1])
-							if reader, ok := g.readers[uint(pin)]; !ok {
-								slog.Printf("[DEBUG] failed to find reader, Pin: %d", pin)
-							} else {
-								var buf bytes.Buffer
-								reader(uint(pin), &buf)
-								slog.Printf("[DEBUG] reader result: %s", buf.String())
-								g.VirtualWrite(pin, buf.String())
-							}
-						case "vw":
-							pin, _ := strconv.Atoi(resp.Values[1])
-							if writer, ok := g.writers[uint(pin)]; !ok {
-								slog.Printf("[DEBUG] failed to find reader, Pin: %d", pin)
-							} else {
-								var buf bytes.Buffer
-								buf.WriteString(resp.Values[2])
-								slog.Printf("[DEBUG] value: %s", resp.Values[2])
-								writer(uint(pin), &buf)
-							}
-						}
-
-					case BLYNK_CMD_RESPONSE:
-
-					case BLYNK_CMD_PING:
-						g.sendPingResponse(resp.MessageId)
-					default:
-						slog.Printf("[ERROR] Processor received unhandled msg: %v", resp)
-					}
-				}
-
+			if buf == nil {
+				pending = pending[:0]
+				break
 			}
+			pending = g.process(pending, buf)
 		}
 	}
+}
 
+// process handles all complete frames of pending and buf and returns the tail of incomplete frame
+func (g *Blynk) process(pending []byte, buf []byte) []byte {
+	//g.log.Printf("[DEBUG] processor received msg: % x", buf)
+	//frame can be split between reads, the tail is kept until the next read
+	pending = append(pending, buf...)
+	br, consumed := decodeFrames(pending)
+	pending = append(pending[:0], pending[consumed:]...)
+
+	for _, resp := range br {
+		g.metrics.MessageReceived(resp.Command, frameSize(resp))
+		switch resp.Command {
+		case BLYNK_CMD_HARDWARE:
+			if g.OnReadFunc != nil {
+				g.OnReadFunc(resp)
+			}
+			if err := g.handleHardware(resp); err != nil {
+				g.log.Printf("[ERROR] processor: %s", err.Error())
+			}
+		case BLYNK_CMD_RESPONSE:
+			if !g.pingReceived(resp.MessageId) && !g.statusReceived(resp) && g.OnResponseFunc != nil {
+				g.OnResponseFunc(resp)
+			}
+		case BLYNK_CMD_INTERNAL:
+			g.handleInternal(resp)
+		case BLYNK_CMD_PING:
+			g.sendPingResponse(resp.MessageId)
+		default:
+			g.log.Printf("[ERROR] Processor received unhandled msg: %v", resp)
+		}
+	}
+	return pending
 }
 
-func (g *Blynk) parseResponce(buf []byte) ([]*BlynkRespose, error) {
-	var resps []*BlynkRespose
-	var resp *BlynkRespose
-	flagStart := 0
-	if len(buf) >= 5 {
-		for len(buf) >= flagStart+5 {
-			resp = new(BlynkRespose)
-			resp.parseHead(buf[flagStart : flagStart+5])
-			lenBody := int(resp.Status)
-
-			if resp.Command == BLYNK_CMD_HARDWARE && resp.Status > 0 && resp.Status < 1024 {
-				if len(buf) >= flagStart+5+lenBody {
-					resp.parseBody(buf[flagStart+5 : flagStart+5+lenBody])
-				} else {
-					slog.Printf("[ERROR] parseResponce: failed parse body, length of buf less than flag")
-				}
+func (g *Blynk) handleHardware(resp *BlynkRespose) error {
+	pin, err := hardwarePin(resp)
+	if err != nil {
+		return err
+	}
 
+	switch resp.Values[0] {
+	case "vr":
+		if value, ok := g.readPin(pin); !ok {
+			g.log.Printf("[DEBUG] failed to find reader, Pin: %d", pin)
+		} else {
+			g.log.Printf("[DEBUG] reader result: %s", value)
+			// the app waits for the answer, so the report policy is not applied
+			if _, err := g.sendMessage(g.virtualWriteMessage(pin, value)); err != nil {
+				return err
 			}
-
-			resps = append(resps, resp)
-			flagStart += 5 + lenBody
+			g.reported(pin, value)
+			g.notifyPin(pin, []string{value})
 		}
+	case "vw":
+		g.writePin(pin, resp.Values[2:])
+		g.notifyPin(pin, resp.Values[2:])
 	}
+	return nil
+}
+
+// readPin calls the reader handler of the pin, it is serialised with other handlers by handlerLock
+func (g *Blynk) readPin(pin int) (string, bool) {
+	reader, ok := g.reader(uint(pin))
+	if !ok {
+		return "", false
+	}
+	g.handlerLock.Lock()
+	defer g.handlerLock.Unlock()
+	var buf bytes.Buffer
+	start := time.Now()
+	reader(uint(pin), &buf)
+	g.metrics.HandlerDuration(uint(pin), time.Since(start))
+	return buf.String(), true
+}
 
+// writePin calls the writer handlers of the pin as for a write from the app,
+// the processor and the gateway call it from different goroutines, so handlers are serialised by handlerLock
+func (g *Blynk) writePin(pin int, values []string) {
+	g.lock.Lock()
+	valuesWriter, hasValues := g.valueWriters[uint(pin)]
+	writer, ok := g.writers[uint(pin)]
+	g.lock.Unlock()
+
+	g.handlerLock.Lock()
+	defer g.handlerLock.Unlock()
+	start := time.Now()
+	defer func() { g.metrics.HandlerDuration(uint(pin), time.Since(start)) }()
+	if hasValues {
+		valuesWriter(uint(pin), values)
+	}
+	if !ok {
+		g.log.Printf("[DEBUG] failed to find writer, Pin: %d", pin)
+		return
+	}
+	var buf bytes.Buffer
+	if len(values) > 0 {
+		buf.WriteString(values[0])
+		g.log.Printf("[DEBUG] value: %s", values[0])
+	}
+	writer(uint(pin), &buf)
+}
+
+func (g *Blynk) reader(pin uint) (func(uint, io.Writer), bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	reader, ok := g.readers[pin]
+	return reader, ok
+}
+
+func (g *Blynk) parseResponce(buf []byte) ([]*BlynkRespose, error) {
+	resps, consumed := decodeFrames(buf)
+	if consumed != len(buf) {
+		return resps, ErrIncompleteFrame
+	}
 	return resps, nil
 }
 
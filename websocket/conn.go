@@ -0,0 +1,177 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Conn carries a byte stream in binary messages, so it can replace the TCP connection of the device.
+// Every Write is sent as one message, Read returns payloads of data messages one after another.
+// Pings are answered and close frames end the stream with io.EOF.
+type Conn struct {
+	conn     net.Conn
+	br       *bufio.Reader
+	client   bool
+	maxFrame int
+
+	readLock sync.Mutex
+	pending  []byte
+	readErr  error
+
+	writeLock sync.Mutex
+	closed    bool
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, client bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	return &Conn{conn: conn, br: br, client: client, maxFrame: MAX_FRAME_SIZE}
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+
+	for len(c.pending) == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+		cr := &countingReader{r: c.br}
+		if err := c.readMessage(cr); err != nil {
+			// timeout before the frame is retried, in the middle of the frame the stream is lost
+			if ne, ok := err.(net.Error); !ok || !ne.Timeout() || cr.n > 0 {
+				c.readErr = err
+			}
+			return 0, err
+		}
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// readMessage reads frames until a data frame is received, control frames are handled on the way
+func (c *Conn) readMessage(r *countingReader) error {
+	for {
+		r.n = 0
+		f, err := ReadFrame(r, c.maxFrame)
+		if err != nil {
+			if err == ErrFrameTooLarge {
+				c.writeClose(CLOSE_MESSAGE_TOO_BIG)
+			}
+			return err
+		}
+		if f.Masked == c.client {
+			// clients mask frames, servers do not
+			c.writeClose(CLOSE_PROTOCOL_ERROR)
+			return ErrProtocol
+		}
+
+		switch f.Opcode {
+		case OP_BINARY, OP_TEXT, OP_CONTINUATION:
+			if len(f.Payload) > 0 {
+				c.pending = f.Payload
+				return nil
+			}
+		case OP_PING:
+			if err := c.writeFrame(OP_PONG, f.Payload); err != nil {
+				return err
+			}
+		case OP_PONG:
+		case OP_CLOSE:
+			code := CLOSE_NORMAL
+			if len(f.Payload) >= 2 {
+				code = binary.BigEndian.Uint16(f.Payload)
+			}
+			c.writeClose(code)
+			return io.EOF
+		default:
+			c.writeClose(CLOSE_PROTOCOL_ERROR)
+			return ErrProtocol
+		}
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.n += n
+	return n, err
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	if err := c.writeFrame(OP_BINARY, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	return c.write(opcode, payload)
+}
+
+func (c *Conn) write(opcode byte, payload []byte) error {
+	f := &Frame{Fin: true, Opcode: opcode, Payload: payload, Masked: c.client}
+	if c.client {
+		if _, err := rand.Read(f.Mask[:]); err != nil {
+			return err
+		}
+	}
+	_, err := c.conn.Write(AppendFrame(nil, f))
+	return err
+}
+
+// writeClose sends close frame once, later writes fail
+func (c *Conn) writeClose(code uint16) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	var payload [2]byte
+	binary.BigEndian.PutUint16(payload[:], code)
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	return c.write(OP_CLOSE, payload[:])
+}
+
+// Close sends close frame and closes the connection without waiting for the answer
+func (c *Conn) Close() error {
+	c.writeClose(CLOSE_NORMAL)
+	return c.conn.Close()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
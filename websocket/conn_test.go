@@ -0,0 +1,252 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve starts a server which upgrades requests and passes the connection to fn, it returns ws:// URL
+func serve(t *testing.T, fn func(c *Conn)) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer c.conn.Close()
+		c.SetDeadline(time.Now().Add(5 * time.Second))
+		fn(c)
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func dial(t *testing.T, url string) *Conn {
+	t.Helper()
+	c, err := Dial(nil, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+	return c
+}
+
+// rawWrite sends the frame as is, e.g. a fragment or a frame with a wrong mask
+func rawWrite(c *Conn, f *Frame) error {
+	_, err := c.conn.Write(AppendFrame(nil, f))
+	return err
+}
+
+func closeCode(f *Frame) uint16 {
+	if f.Opcode != OP_CLOSE || len(f.Payload) < 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(f.Payload)
+}
+
+func TestEcho(t *testing.T) {
+	paths := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.RequestURI()
+		c, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.Copy(c, c)
+	}))
+	defer srv.Close()
+
+	c := dial(t, "ws"+strings.TrimPrefix(srv.URL, "http")+"/websocket?x=1")
+	if path := <-paths; path != "/websocket?x=1" {
+		t.Fatalf("request path %s", path)
+	}
+	for _, msg := range []string{"hello", strings.Repeat("y", 70000)} {
+		if _, err := c.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len(msg))
+		if _, err := io.ReadFull(c, buf); err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != msg {
+			t.Fatalf("echo of %d bytes differs", len(msg))
+		}
+	}
+}
+
+func TestClientFramesMasked(t *testing.T) {
+	frames := make(chan *Frame, 1)
+	url := serve(t, func(c *Conn) {
+		f, err := ReadFrame(c.br, MAX_FRAME_SIZE)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		frames <- f
+	})
+
+	c := dial(t, url)
+	if _, err := c.Write([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	f := <-frames
+	if !f.Masked || f.Opcode != OP_BINARY || !f.Fin || string(f.Payload) != "data" {
+		t.Fatalf("client frame %+v", f)
+	}
+}
+
+func TestMaskedServerFrameRejected(t *testing.T) {
+	replies := make(chan *Frame, 1)
+	url := serve(t, func(c *Conn) {
+		rawWrite(c, &Frame{Fin: true, Opcode: OP_BINARY, Masked: true, Mask: [4]byte{1, 2, 3, 4}, Payload: []byte("x")})
+		f, err := ReadFrame(c.br, MAX_FRAME_SIZE)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		replies <- f
+	})
+
+	c := dial(t, url)
+	if _, err := c.Read(make([]byte, 8)); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("read error %v, want ErrProtocol", err)
+	}
+	if code := closeCode(<-replies); code != CLOSE_PROTOCOL_ERROR {
+		t.Fatalf("close code %d, want %d", code, CLOSE_PROTOCOL_ERROR)
+	}
+}
+
+func TestFragmentedMessageWithPing(t *testing.T) {
+	replies := make(chan *Frame, 1)
+	url := serve(t, func(c *Conn) {
+		rawWrite(c, &Frame{Opcode: OP_BINARY, Payload: []byte("he")})
+		rawWrite(c, &Frame{Fin: true, Opcode: OP_PING, Payload: []byte("p")})
+		rawWrite(c, &Frame{Fin: true, Opcode: OP_CONTINUATION, Payload: []byte("llo")})
+		f, err := ReadFrame(c.br, MAX_FRAME_SIZE)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		replies <- f
+	})
+
+	c := dial(t, url)
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("read %q", buf)
+	}
+	pong := <-replies
+	if pong.Opcode != OP_PONG || !pong.Masked || string(pong.Payload) != "p" {
+		t.Fatalf("ping answer %+v", pong)
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	replies := make(chan *Frame, 1)
+	url := serve(t, func(c *Conn) {
+		rawWrite(c, &Frame{Fin: true, Opcode: OP_CLOSE, Payload: []byte{0x03, 0xE9}})
+		f, err := ReadFrame(c.br, MAX_FRAME_SIZE)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		replies <- f
+	})
+
+	c := dial(t, url)
+	if _, err := c.Read(make([]byte, 8)); err != io.EOF {
+		t.Fatalf("read error %v, want io.EOF", err)
+	}
+	if code := closeCode(<-replies); code != 1001 {
+		t.Fatalf("close code %d, want the code of the server", code)
+	}
+	if _, err := c.Write([]byte("x")); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("write after close: %v", err)
+	}
+	if _, err := c.Read(make([]byte, 8)); err != io.EOF {
+		t.Fatalf("second read error %v, want io.EOF", err)
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	errs := make(chan error, 1)
+	url := serve(t, func(c *Conn) {
+		_, err := c.Read(make([]byte, 8))
+		errs <- err
+	})
+
+	c := dial(t, url)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != io.EOF {
+		t.Fatalf("server read error %v, want io.EOF", err)
+	}
+}
+
+func TestFrameTooLarge(t *testing.T) {
+	replies := make(chan *Frame, 1)
+	url := serve(t, func(c *Conn) {
+		rawWrite(c, &Frame{Fin: true, Opcode: OP_BINARY, Payload: make([]byte, 100)})
+		f, err := ReadFrame(c.br, MAX_FRAME_SIZE)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		replies <- f
+	})
+
+	c := dial(t, url)
+	c.maxFrame = 10
+	if _, err := c.Read(make([]byte, 8)); err != ErrFrameTooLarge {
+		t.Fatalf("read error %v, want ErrFrameTooLarge", err)
+	}
+	if code := closeCode(<-replies); code != CLOSE_MESSAGE_TOO_BIG {
+		t.Fatalf("close code %d, want %d", code, CLOSE_MESSAGE_TOO_BIG)
+	}
+}
+
+func TestUpgradeRejectsPlainRequest(t *testing.T) {
+	url := serve(t, func(c *Conn) {
+		t.Error("plain request is upgraded")
+	})
+	resp, err := http.Get("http" + strings.TrimPrefix(url, "ws"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestDialWrongAccept(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: "+acceptKey("other")+"\r\n\r\n")
+		bufio.NewReader(conn).ReadByte()
+	}))
+	defer srv.Close()
+
+	if _, err := Dial(nil, "ws"+strings.TrimPrefix(srv.URL, "http"), nil); !errors.Is(err, ErrUnexpectedReply) {
+		t.Fatalf("dial error %v, want ErrUnexpectedReply", err)
+	}
+}
@@ -0,0 +1,138 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	OP_CONTINUATION byte = 0x0
+	OP_TEXT         byte = 0x1
+	OP_BINARY       byte = 0x2
+	OP_CLOSE        byte = 0x8
+	OP_PING         byte = 0x9
+	OP_PONG         byte = 0xA
+)
+
+const (
+	CLOSE_NORMAL          uint16 = 1000
+	CLOSE_PROTOCOL_ERROR  uint16 = 1002
+	CLOSE_MESSAGE_TOO_BIG uint16 = 1009
+
+	MAX_FRAME_SIZE   = 1 << 20
+	MAX_CONTROL_SIZE = 125
+)
+
+var (
+	ErrProtocol        = errors.New("websocket: protocol error")
+	ErrFrameTooLarge   = errors.New("websocket: frame exceeds size limit")
+	ErrUnexpectedReply = errors.New("websocket: unexpected handshake reply")
+)
+
+// Frame is one RFC 6455 frame, Payload is unmasked
+type Frame struct {
+	Fin     bool
+	Opcode  byte
+	Masked  bool
+	Mask    [4]byte
+	Payload []byte
+}
+
+func (f *Frame) IsControl() bool {
+	return f.Opcode&0x8 != 0
+}
+
+// AppendFrame encodes the frame to dst, the payload is masked with f.Mask when f.Masked is set
+func AppendFrame(dst []byte, f *Frame) []byte {
+	b0 := f.Opcode & 0x0F
+	if f.Fin {
+		b0 |= 0x80
+	}
+	dst = append(dst, b0)
+
+	var b1 byte
+	if f.Masked {
+		b1 = 0x80
+	}
+	n := len(f.Payload)
+	switch {
+	case n <= 125:
+		dst = append(dst, b1|byte(n))
+	case n <= 0xFFFF:
+		dst = append(dst, b1|126, byte(n>>8), byte(n))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		dst = append(dst, b1|127)
+		dst = append(dst, ext[:]...)
+	}
+
+	if !f.Masked {
+		return append(dst, f.Payload...)
+	}
+	dst = append(dst, f.Mask[:]...)
+	start := len(dst)
+	dst = append(dst, f.Payload...)
+	mask(dst[start:], f.Mask)
+	return dst
+}
+
+// ReadFrame reads one frame, payloads larger than max are rejected
+func ReadFrame(r io.Reader, max int) (*Frame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	f := &Frame{
+		Fin:    head[0]&0x80 != 0,
+		Opcode: head[0] & 0x0F,
+		Masked: head[1]&0x80 != 0,
+	}
+	if head[0]&0x70 != 0 {
+		return nil, fmt.Errorf("%w, reserved bits are set", ErrProtocol)
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if f.IsControl() && (length > MAX_CONTROL_SIZE || !f.Fin) {
+		return nil, fmt.Errorf("%w, bad control frame", ErrProtocol)
+	}
+	if length > uint64(max) {
+		return nil, ErrFrameTooLarge
+	}
+
+	if f.Masked {
+		if _, err := io.ReadFull(r, f.Mask[:]); err != nil {
+			return nil, err
+		}
+	}
+	f.Payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.Payload); err != nil {
+		return nil, err
+	}
+	if f.Masked {
+		mask(f.Payload, f.Mask)
+	}
+	return f, nil
+}
+
+func mask(buf []byte, key [4]byte) {
+	for i := range buf {
+		buf[i] ^= key[i&3]
+	}
+}
@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// example of RFC 6455 section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("acceptKey() = %s", got)
+	}
+}
+
+func TestMaskedFrame(t *testing.T) {
+	// masked "Hello" of RFC 6455 section 5.7
+	want := []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58}
+	f := &Frame{Fin: true, Opcode: OP_TEXT, Masked: true, Mask: [4]byte{0x37, 0xfa, 0x21, 0x3d}, Payload: []byte("Hello")}
+	if got := AppendFrame(nil, f); !bytes.Equal(got, want) {
+		t.Fatalf("AppendFrame() = % x", got)
+	}
+	if string(f.Payload) != "Hello" {
+		t.Fatalf("payload of the frame is changed to %q", f.Payload)
+	}
+
+	got, err := ReadFrame(bytes.NewReader(want), MAX_FRAME_SIZE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Fin || got.Opcode != OP_TEXT || !got.Masked || string(got.Payload) != "Hello" {
+		t.Fatalf("ReadFrame() = %+v", got)
+	}
+}
+
+func TestFrameLengths(t *testing.T) {
+	for _, n := range []int{0, 125, 126, 0xFFFF, 0x10000} {
+		for _, masked := range []bool{false, true} {
+			f := &Frame{Fin: true, Opcode: OP_BINARY, Masked: masked, Mask: [4]byte{1, 2, 3, 4}, Payload: bytes.Repeat([]byte{'x'}, n)}
+			got, err := ReadFrame(bytes.NewReader(AppendFrame(nil, f)), MAX_FRAME_SIZE)
+			if err != nil {
+				t.Fatalf("length %d masked %v: %v", n, masked, err)
+			}
+			if got.Masked != masked || !bytes.Equal(got.Payload, f.Payload) {
+				t.Fatalf("length %d masked %v: payload of %d bytes", n, masked, len(got.Payload))
+			}
+		}
+	}
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"reserved bits", []byte{0xC2, 0x00}, ErrProtocol},
+		{"fragmented control", []byte{0x09, 0x00}, ErrProtocol},
+		{"long control", AppendFrame(nil, &Frame{Fin: true, Opcode: OP_PING, Payload: make([]byte, 126)}), ErrProtocol},
+		{"too large", AppendFrame(nil, &Frame{Fin: true, Opcode: OP_BINARY, Payload: make([]byte, 11)}), ErrFrameTooLarge},
+	}
+	for _, tt := range tests {
+		if _, err := ReadFrame(bytes.NewReader(tt.data), 10); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package blynk
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TimeInput struct {
+	Start      time.Duration
+	Stop       time.Duration
+	HasStart   bool
+	HasStop    bool
+	Timezone   string
+	TZOffset   time.Duration
+	Days       []time.Weekday
+	StartEvent string
+	StopEvent  string
+}
+
+func DecodeRGB(values []string) (color.RGBA, error) {
+	c := color.RGBA{A: 0xFF}
+	if len(values) < 3 {
+		return c, fmt.Errorf("zergba: expected 3 values, got %d", len(values))
+	}
+	channels := []*uint8{&c.R, &c.G, &c.B}
+	for i, ch := range channels {
+		v, err := strconv.ParseUint(values[i], 10, 8)
+		if err != nil {
+			return c, fmt.Errorf("zergba: bad channel value %q", values[i])
+		}
+		*ch = uint8(v)
+	}
+	return c, nil
+}
+
+func DecodeJoystick(values []string) (int, int, error) {
+	if len(values) < 2 {
+		return 0, 0, fmt.Errorf("joystick: expected 2 values, got %d", len(values))
+	}
+	x, err := strconv.Atoi(values[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("joystick: bad x value %q", values[0])
+	}
+	y, err := strconv.Atoi(values[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("joystick: bad y value %q", values[1])
+	}
+	return x, y, nil
+}
+
+func DecodeFloat(values []string) (float64, error) {
+	if len(values) < 1 {
+		return 0, fmt.Errorf("float: value is missing")
+	}
+	v, err := strconv.ParseFloat(values[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("float: bad value %q", values[0])
+	}
+	return v, nil
+}
+
+// DecodeTimeInput parses Time Input widget payload: start, stop, timezone name, week days and timezone offset.
+// Start and stop are seconds since midnight, empty when not set or "sr"/"ss" for sunrise and sunset.
+func DecodeTimeInput(values []string) (TimeInput, error) {
+	var ti TimeInput
+	if len(values) < 2 {
+		return ti, fmt.Errorf("time input: expected at least start and stop, got %d values", len(values))
+	}
+
+	var err error
+	if ti.Start, ti.HasStart, ti.StartEvent, err = parseTimeOfDay(values[0]); err != nil {
+		return ti, err
+	}
+	if ti.Stop, ti.HasStop, ti.StopEvent, err = parseTimeOfDay(values[1]); err != nil {
+		return ti, err
+	}
+	if len(values) > 2 {
+		ti.Timezone = values[2]
+	}
+	if len(values) > 3 && values[3] != "" {
+		for _, d := range strings.Split(values[3], ",") {
+			n, err := strconv.Atoi(d)
+			if err != nil || n < 1 || n > 7 {
+				return ti, fmt.Errorf("time input: bad week day %q", d)
+			}
+			// Blynk numbers days from Monday (1) to Sunday (7)
+			ti.Days = append(ti.Days, time.Weekday(n%7))
+		}
+	}
+	if len(values) > 4 && values[4] != "" {
+		offset, err := strconv.Atoi(values[4])
+		if err != nil {
+			return ti, fmt.Errorf("time input: bad timezone offset %q", values[4])
+		}
+		ti.TZOffset = time.Duration(offset) * time.Second
+	}
+	return ti, nil
+}
+
+func parseTimeOfDay(v string) (time.Duration, bool, string, error) {
+	switch v {
+	case "":
+		return 0, false, "", nil
+	case "sr", "ss":
+		return 0, false, v, nil
+	}
+	sec, err := strconv.Atoi(v)
+	if err != nil || sec < 0 {
+		return 0, false, "", fmt.Errorf("time input: bad time %q", v)
+	}
+	return time.Duration(sec) * time.Second, true, "", nil
+}
+
+func (g *Blynk) AddRGBHandler(pin uint, fn func(pin uint, c color.RGBA)) {
+	g.setValuesHandler(pin, func(pin uint, values []string) {
+		c, err := DecodeRGB(values)
+		if err != nil {
+			g.log.Printf("[ERROR] %s, Pin: %d", err.Error(), pin)
+			return
+		}
+		fn(pin, c)
+	})
+}
+
+func (g *Blynk) AddJoystickHandler(pin uint, fn func(pin uint, x int, y int)) {
+	g.setValuesHandler(pin, func(pin uint, values []string) {
+		x, y, err := DecodeJoystick(values)
+		if err != nil {
+			g.log.Printf("[ERROR] %s, Pin: %d", err.Error(), pin)
+			return
+		}
+		fn(pin, x, y)
+	})
+}
+
+// AddSliderHandler is used for slider and step widgets, the value is clamped to [min, max]
+func (g *Blynk) AddSliderHandler(pin uint, min float64, max float64, fn func(pin uint, value float64)) {
+	g.setValuesHandler(pin, func(pin uint, values []string) {
+		v, err := DecodeFloat(values)
+		if err != nil {
+			g.log.Printf("[ERROR] %s, Pin: %d", err.Error(), pin)
+			return
+		}
+		fn(pin, math.Max(min, math.Min(max, v)))
+	})
+}
+
+func (g *Blynk) AddTimeInputHandler(pin uint, fn func(pin uint, ti TimeInput)) {
+	g.setValuesHandler(pin, func(pin uint, values []string) {
+		ti, err := DecodeTimeInput(values)
+		if err != nil {
+			g.log.Printf("[ERROR] %s, Pin: %d", err.Error(), pin)
+			return
+		}
+		fn(pin, ti)
+	})
+}
+
+// DeleteTypedHandler removes any typed handler (RGB, joystick, slider, time input, GPS) from the pin
+func (g *Blynk) DeleteTypedHandler(pin uint) {
+	g.deleteValuesHandler(pin)
+}
@@ -0,0 +1,102 @@
+package blynk
+
+import (
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeRGB(t *testing.T) {
+	c, err := DecodeRGB([]string{"255", "0", "128"})
+	if err != nil || c != (color.RGBA{R: 255, G: 0, B: 128, A: 255}) {
+		t.Fatalf("DecodeRGB() = %v, %v", c, err)
+	}
+	for _, values := range [][]string{nil, {"1", "2"}, {"256", "0", "0"}, {"1", "-1", "0"}, {"1", "2", "x"}} {
+		if _, err := DecodeRGB(values); err == nil {
+			t.Errorf("DecodeRGB(%q): expected error", values)
+		}
+	}
+}
+
+func TestDecodeJoystick(t *testing.T) {
+	x, y, err := DecodeJoystick([]string{"-128", "255"})
+	if err != nil || x != -128 || y != 255 {
+		t.Fatalf("DecodeJoystick() = %d, %d, %v", x, y, err)
+	}
+	for _, values := range [][]string{nil, {"1"}, {"x", "1"}, {"1", "1.5"}} {
+		if _, _, err := DecodeJoystick(values); err == nil {
+			t.Errorf("DecodeJoystick(%q): expected error", values)
+		}
+	}
+}
+
+func TestDecodeFloat(t *testing.T) {
+	v, err := DecodeFloat([]string{"-12.75", "ignored"})
+	if err != nil || v != -12.75 {
+		t.Fatalf("DecodeFloat() = %v, %v", v, err)
+	}
+	for _, values := range [][]string{nil, {""}, {"1,5"}} {
+		_, err := DecodeFloat(values)
+		if err == nil {
+			t.Errorf("DecodeFloat(%q): expected error", values)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), "float: ") {
+			t.Errorf("DecodeFloat(%q): error %q", values, err)
+		}
+	}
+}
+
+func TestDecodeTimeInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   TimeInput
+	}{
+		{"full", []string{"3600", "7200", "Europe/Kiev", "1,7", "10800"}, TimeInput{
+			Start: time.Hour, Stop: 2 * time.Hour, HasStart: true, HasStop: true,
+			Timezone: "Europe/Kiev", TZOffset: 3 * time.Hour, Days: []time.Weekday{time.Monday, time.Sunday},
+		}},
+		{"not set", []string{"", ""}, TimeInput{}},
+		{"sun events", []string{"sr", "ss", "UTC", "", ""}, TimeInput{StartEvent: "sr", StopEvent: "ss", Timezone: "UTC"}},
+		{"start only", []string{"0", ""}, TimeInput{HasStart: true}},
+	}
+	for _, tt := range tests {
+		got, err := DecodeTimeInput(tt.values)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, values := range [][]string{{"1"}, {"-1", ""}, {"x", ""}, {"", "", "UTC", "0"}, {"", "", "UTC", "8"}, {"", "", "UTC", "1,", ""}, {"", "", "UTC", "", "3h"}} {
+		if _, err := DecodeTimeInput(values); err == nil {
+			t.Errorf("DecodeTimeInput(%q): expected error", values)
+		}
+	}
+}
+
+func TestSliderHandlerClamps(t *testing.T) {
+	g, _ := newRecordBlynk()
+	var got []float64
+	g.AddSliderHandler(2, 0, 100, func(pin uint, v float64) {
+		got = append(got, v)
+	})
+	for _, v := range []string{"-5", "50.5", "101", "bad"} {
+		g.handleHardware(&BlynkRespose{Command: BLYNK_CMD_HARDWARE, Values: []string{"vw", "2", v}})
+	}
+	if want := []float64{0, 50.5, 100}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("values %v, want %v", got, want)
+	}
+
+	g.DeleteTypedHandler(2)
+	g.handleHardware(&BlynkRespose{Command: BLYNK_CMD_HARDWARE, Values: []string{"vw", "2", "1"}})
+	if len(got) != 3 {
+		t.Fatalf("handler is called after delete, %v", got)
+	}
+}